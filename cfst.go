@@ -1,23 +1,41 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
+	"github.com/SonzaiEkkusu/Proxy-Finder/server"
 	"github.com/SonzaiEkkusu/Proxy-Finder/task"
 	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
 )
 
 var (
 	version, versionNew string
+	runConfig           = make(map[string]string) // Parameter yang ditentukan pada baris perintah, dicatat ke file riwayat
 )
 
+// Subperintah, contoh: cfst locations update
+var commands = map[string]func(args []string) int{
+	"locations": locationsCommand,
+	"serve":     serveCommand,
+	"diff":      diffCommand,
+	"history":   historyCommand,
+}
+
 func init() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	var printVersion bool
 	var help = `
 CloudflareSpeedTest ` + version + `
@@ -33,17 +51,73 @@ Parameter:
         Jumlah pengujian unduh; setelah mengurutkan latensi, jumlah pengujian unduh yang dilakukan dari latensi terendah; (default 10)
     -dt 10
         Waktu pengujian unduh; durasi maksimum pengujian unduh untuk satu IP, jangan terlalu singkat; (default 10 detik)
+    -dp 1
+        Jumlah pengujian unduh paralel; jumlah IP yang diuji kecepatan unduhnya secara bersamaan, pengujian paralel berbagi tautan yang sama sehingga dapat mendistorsi hasil per IP; (default 1, maksimum 100)
+    -dc 1
+        Jumlah koneksi per IP; jumlah koneksi bersamaan ke IP yang sama dalam satu pengujian unduh, kecepatan unduh adalah gabungan semua koneksi, kecepatan setiap koneksi ditulis ke file hasil; (default 1, maksimum 32)
+    -dr 1
+        Jumlah percobaan ulang unduh; jika pengujian unduh gagal sementara (dial, tls, timeout, reset, status 429/5xx) coba ulang sebanyak jumlah yang ditentukan, alasan kegagalan ditulis ke file hasil; (default 1)
+    -drw 500
+        Jeda percobaan ulang unduh; jeda sebelum setiap percobaan ulang; (default 500 ms)
+    -dpl
+        Ukur kapasitas tautan; sebelum pengujian unduh paralel, ukur kapasitas tautan melalui IP terbaik dan peringatkan jika [-dp] akan menjenuhkan tautan, pengukuran ini mengunduh 1+[-dp] kali selama [-dt] detik dengan satu koneksi (terlepas dari [-dc]) dan ikut dihitung dalam [-data-budget]; (default nonaktif)
+    -verify
+        Verifikasi respons unduh; periksa header identitas penyedia (Cloudflare: Server dan CF-RAY), IP dengan respons yang dicegat/diubah ditandai tidak valid dan bukan cepat; (default nonaktif)
+    -verify-size 200000000
+        Content-Length yang diharapkan dari respons unduh; (default tidak diperiksa)
+    -verify-sha256 <hex>
+        Checksum SHA-256 file unduh yang diharapkan, hanya diperiksa jika file selesai diunduh dalam [-dt]; (default tidak diperiksa)
+    -verify-cert example.com
+        Nama yang harus ada pada sertifikat TLS respons unduh (nama host atau subject sertifikat); (default tidak diperiksa)
+    -up
+        Aktifkan pengujian unggah; setelah pengujian unduh, uji kecepatan unggah [-dn] IP hasil teratas dengan mengirim data ke [-up-url] selama [-dt] detik, [-dp] IP diuji bersamaan, IP lainnya tetap ada dalam hasil tanpa kecepatan unggah kecuali [-ul] ditentukan; (default nonaktif)
+    -up-url https://speed.cloudflare.com/__up
+        Alamat pengujian unggah; alamat yang menerima data POST untuk pengujian unggah; (default https://speed.cloudflare.com/__up)
+    -speed-sort download
+        Dasar pengurutan kecepatan; download (kecepatan unduh), upload (kecepatan unggah) atau sum (unduh + unggah), juga menentukan kecepatan untuk [-sort speed] dan skor; (default download)
+    -sort score
+        Dasar pengurutan; latency (latensi), speed (kecepatan), loss (kehilangan paket), jitter atau score (skor gabungan), berlaku untuk pemilihan IP yang diuji kecepatan unduhnya,
+        hasil yang ditampilkan [-p] dan file hasil; (default kehilangan paket lalu latensi untuk pemilihan IP, kecepatan untuk hasil)
+    -score-weights latency=1,speed=1,loss=1,jitter=0.5
+        Bobot skor gabungan; skor 0~100 dihitung dengan menormalisasi setiap metrik relatif terhadap IP lain dalam hasil yang sama lalu dirata-rata sesuai bobot, 0 untuk mengabaikan metrik; (default latency=1,speed=1,loss=1,jitter=0.5)
+    -data-budget 100MB
+        Batas data pengujian; batas total data yang digunakan oleh semua pengujian unduh/unggah (contoh: 500KB, 100MB, 1.5GB), pengujian dihentikan setelah batas tercapai, pemeriksaan awal alamat [-url] tidak mengunduh data saat batas ditentukan, pengukuran kapasitas tautan [-dpl] ikut dihitung, cocok untuk data seluler terbatas (Termux); (default tidak dibatasi)
     -tp 443
         Port pengujian yang ditentukan; port yang digunakan untuk pengujian latensi/unduh; (default port 443)
     -url https://cf.xiu2.xyz/url
         Alamat pengujian yang ditentukan; alamat yang digunakan untuk pengujian latensi (HTTPing)/unduh, alamat default tidak dijamin ketersediaannya, disarankan untuk menggunakan alamat sendiri;
+        dapat digunakan berkali-kali, setiap IP bergiliran menggunakan alamat yang berbeda dan beralih ke alamat berikutnya jika alamat gagal (kode status selain 200 atau file terlalu kecil, bukan kegagalan koneksi ke IP), HTTPing menggunakan alamat pertama;
+    -skip-preflight
+        Lewati pemeriksaan awal; secara default sebelum pengujian latensi setiap [-url] diakses melalui DNS biasa untuk memeriksa kode status, pengalihan, header penyedia dan ukuran file dibandingkan [-dt]; (default diperiksa)
 
     -httping
         Ganti mode pengujian; ubah mode pengujian latensi menjadi protokol HTTP, alamat pengujian menggunakan parameter [-url]; (default TCPing)
-    -httping-code 200
-        Kode status yang valid; kode status HTTP yang valid untuk pengujian latensi HTTPing, hanya satu kode yang diperbolehkan; (default 200 301 302)
+    -httping-code 200,2xx,300-399
+        Kode status yang valid; kode status HTTP yang valid untuk pengujian latensi HTTPing, mendukung banyak kode, pola (2xx) dan rentang (200-299), dipisahkan dengan koma; (default 200 301 302)
+    -httping-header Server:cloudflare
+        Header respons yang wajib ada; format Nama atau Nama:nilai (nilai dicocokkan sebagai substring, tidak peka huruf besar/kecil), dapat digunakan berkali-kali; (default kosong)
+    -httping-body uag=Mozilla/5.0
+        Substring body respons yang wajib ada; jika diisi, pengujian pertama menggunakan GET dan bukan HEAD; (default kosong)
+    -httping-body-regex "colo=[A-Z]{3}"
+        Regex body respons yang wajib cocok; jika diisi, pengujian pertama menggunakan GET dan bukan HEAD; (default kosong)
     -cfcolo HKG,KHH,NRT,LAX,SEA,SJC,FRA,MAD
-        Cocokkan lokasi tertentu; nama lokasi menggunakan kode tiga huruf bandara lokal, dipisahkan dengan koma, hanya tersedia untuk mode HTTPing; (default semua lokasi)
+        Cocokkan lokasi tertentu; nama lokasi menggunakan kode tiga huruf bandara lokal, dipisahkan dengan koma, dalam mode TCPing colo dideteksi melalui [-trace-url]; (default semua lokasi)
+    -region "Asia Pacific"
+        Pilih colo berdasarkan wilayah; menggunakan data lokasi [-locations], dipisahkan dengan koma, digabungkan dengan [-cfcolo] dan [-country]; (default semua wilayah)
+    -country SG,ID
+        Pilih colo berdasarkan negara; kode negara dua huruf dari data lokasi [-locations], dipisahkan dengan koma; (default semua negara)
+    -cfcolo-exclude HKG,NRT
+        Kecualikan lokasi tertentu; colo yang dikecualikan dari hasil, dipisahkan dengan koma; (default kosong)
+    -locations locations.json
+        File data lokasi; digunakan untuk [-region] [-country] dan melengkapi kolom kota, negara, wilayah pada hasil, jika file tidak ada atau rusak maka gunakan data bawaan program; (default locations.json)
+    -colo
+        Deteksi colo; setelah pengujian latensi, dapatkan colo setiap IP melalui endpoint trace sehingga hasil mode TCPing juga memiliki kolom colo; (default nonaktif, otomatis aktif jika [-cfcolo] digunakan)
+    -trace-url https://speed.cloudflare.com/cdn-cgi/trace
+        Alamat endpoint trace; digunakan untuk deteksi colo, jika port pengujian adalah port tanpa TLS maka otomatis menggunakan http://, penyedia tanpa endpoint trace membaca colo dari header respons [-url]; (default endpoint trace penyedia)
+    -provider cloudflare
+        Penyedia CDN; menentukan format header lokasi node, endpoint trace, port default dan rentang IP resmi, pilihan: akamai, cloudfront, cloudflare, fastly, gcore (akamai tanpa lokasi node, gcore dengan kode lokasi sendiri seperti SG1 sehingga keduanya tidak mendukung filter lokasi); (default cloudflare)
+    -fetch-ip
+        Unduh rentang IP resmi; unduh rentang IP resmi penyedia [-provider] ke file [-f] sebelum pengujian; (default nonaktif)
 
     -tl 200
         Batas atas latensi rata-rata; hanya tampilkan IP dengan latensi rata-rata di bawah batas yang ditentukan, kondisi batas atas dan bawah dapat digunakan bersama; (default 9999 ms)
@@ -53,6 +127,17 @@ Parameter:
         Batas atas tingkat kehilangan paket; hanya tampilkan IP dengan tingkat kehilangan paket di bawah atau sama dengan batas yang ditentukan, rentang 0.00~1.00, 0 menghilangkan IP dengan kehilangan paket; (default 1.00)
     -sl 5
         Batas bawah kecepatan unduh; hanya tampilkan IP dengan kecepatan unduh di atas batas yang ditentukan, pengujian akan berhenti setelah mencapai jumlah yang ditentukan [-dn]; (default 0.00 MB/s)
+    -sla 0.5
+        Rasio penghentian awal; jika [-sl] digunakan dan setelah waktu pemanasan (maksimal 2 detik) kecepatan stabil masih di bawah [-sl] x rasio, pengujian unduh IP tersebut dihentikan lebih awal, 0 untuk menonaktifkan; (default 0.5)
+    -tlb 50
+        Batas atas kenaikan latensi saat beban; selama pengujian unduh latensi TCP ke IP yang sama diukur berkala (bufferbloat), IP dengan latensi saat beban melebihi latensi tanpa beban (diukur dengan probe yang sama sesaat sebelum pengujian unduh) lebih dari batas yang ditentukan tidak dianggap memenuhi syarat; (default tidak difilter)
+    -ul 1
+        Batas bawah kecepatan unggah; hanya tampilkan IP dengan kecepatan unggah di atas batas yang ditentukan, hanya berlaku untuk IP yang diuji unggah jika [-up] diaktifkan; (default 0.00 MB/s)
+    -filter 'loss < 0.1 && delay < 150ms && colo in ["SIN","HKG"] && port == 443'
+        Ekspresi filter hasil; dievaluasi setelah setiap tahap (latensi, colo, unduh, unggah) bersama [-tl] [-tll] [-tlr] [-sl] [-ul], kondisi pada field yang belum diukur
+        (misalnya speed sebelum pengujian unduh) tidak menyaring IP sampai field tersebut diukur, operator: && || ! ( ) == != < <= > >= in [...]
+        field: ip (teks IP atau CIDR), port, tls, sent, received, loss (0~1 atau 10%), delay/latency dan jitter (150ms, 1s, angka tanpa satuan = ms), colo, city, country, region (teks),
+        speed/download, peak, upload (MB/s), loaded_delay, throttled, aborted (true/false); field lokasi mengaktifkan [-colo] secara otomatis; (default kosong)
 
     -p 10
         Jumlah hasil yang ditampilkan; setelah pengujian, langsung tampilkan jumlah hasil yang ditentukan, jika 0, tidak menampilkan hasil dan langsung keluar; (default 10 hasil)
@@ -60,8 +145,23 @@ Parameter:
         File data rentang IP; jika path mengandung spasi, harap gunakan tanda kutip; mendukung rentang IP CDN lainnya; (default ip.txt)
     -ip 1.1.1.1,2.2.2.2/24,2606:4700::/32
         Data rentang IP yang ditentukan; langsung tentukan data rentang IP yang ingin diuji melalui parameter, dipisahkan dengan koma; (default kosong)
+    -from-result result.csv
+        Uji ulang hasil sebelumnya; ambil IP dan port dari file hasil sebelumnya (CSV/JSON/NDJSON) alih-alih rentang IP, jalankan ulang pengujian latensi dan unduh,
+        file hasil baru berisi kolom hasil sebelumnya dan selisihnya; [-tp] dan [-dn] tetap dapat digunakan untuk mengganti port (semua IP diuji ulang melalui port tersebut) dan jumlah pengujian unduh, file hasil dengan beberapa port memerlukan [-tp]; (default nonaktif)
+    -top 50
+        Jumlah IP teratas yang diuji ulang; hanya berlaku dengan [-from-result]; (default semua)
     -o result.csv
-        Menulis file hasil; jika path mengandung spasi, harap gunakan tanda kutip; jika kosong, tidak menulis ke file [-o ""]; (default result.csv)
+        Menulis file hasil; jika path mengandung spasi, harap gunakan tanda kutip; jika kosong, tidak menulis ke file [-o ""];
+        dapat digunakan berkali-kali untuk menulis beberapa file sekaligus, format ditentukan dari ekstensi (.csv, .json, .ndjson/.jsonl) atau [-format]; (default result.csv)
+    -format csv
+        Format file hasil; csv, json (satu dokumen dengan metadata) atau ndjson (satu objek JSON per baris), JSON berisi nilai mentah
+        (latensi dalam nanodetik, kecepatan dalam byte/detik, kehilangan paket sebagai rasio) dan versi skema; (default csv)
+    -series series.csv
+        Menulis deret waktu kecepatan unduh; byte yang diterima setiap IP per potongan waktu ([-dt] / 100) untuk melihat fase awal, pembatasan kecepatan dan macet; (default tidak ditulis)
+    -history history.ndjson
+        Catat ke file riwayat; hasil setiap pengujian ditambahkan ke file (satu baris JSON per pengujian) beserta waktu, identitas jaringan dan parameter, lihat subperintah history; (default tidak dicatat)
+    -network rumah
+        Nama jaringan yang dicatat ke file riwayat; untuk membedakan riwayat dari jaringan berbeda, contoh: rumah, kantor, seluler; (default kosong)
 
     -dd
         Nonaktifkan pengujian unduh; jika dinonaktifkan, hasil pengujian akan diurutkan berdasarkan latensi (default diurutkan berdasarkan kecepatan unduh); (default aktif)
@@ -72,29 +172,78 @@ Parameter:
         Tampilkan versi program + periksa pembaruan versi
     -h
         Tampilkan panduan bantuan
+
+Subperintah:
+    locations update [-locations locations.json] [-url https://speed.cloudflare.com/locations]
+        Perbarui file data lokasi dari https://speed.cloudflare.com/locations, lalu tampilkan colo yang ditambahkan/dihapus
+    serve [-addr :8080] [-cert cert.pem -key key.pem] [-colo LOC]
+        Jalankan server uji kecepatan sendiri dengan endpoint /__down?bytes=N, /__up dan /cdn-cgi/trace, dapat ditempatkan di belakang Cloudflare sebagai origin [-url] [-up-url] [-trace-url]
+    diff [-json] lama.csv baru.csv
+        Bandingkan dua file hasil (CSV/JSON/NDJSON): IP baru, IP hilang, perubahan peringkat, selisih latensi/kecepatan unduh dan perubahan colo, [-json] untuk output JSON
+    history [-history history.ndjson] [-network rumah] [-last 10] [-json] [runs | ip 1.1.1.1 | colo SIN | good [-tl 200] [-sl 5]]
+        Tampilkan riwayat dari file [-history]: daftar pengujian (runs), latensi/kecepatan unduh sebuah IP atau colo dari waktu ke waktu,
+        atau IP yang ada dalam hasil [-last] pengujian terakhir dan selalu memenuhi [-tl] [-sl] (good); (default runs)
 `
-	var minDelay, maxDelay, downloadTime int
+	var minDelay, maxDelay, downloadTime, retryWait, maxLoadedDelta int
+	var dataBudget, scoreWeights string
 	var maxLossRate float64
 	flag.IntVar(&task.Routines, "n", 200, "Jumlah thread pengujian latensi")
 	flag.IntVar(&task.PingTimes, "t", 4, "Jumlah pengujian latensi")
 	flag.IntVar(&task.TestCount, "dn", 10, "Jumlah pengujian unduh")
 	flag.IntVar(&downloadTime, "dt", 10, "Durasi pengujian unduh")
+	flag.IntVar(&task.Parallel, "dp", 1, "Jumlah pengujian unduh paralel")
+	flag.IntVar(&task.Connections, "dc", 1, "Jumlah koneksi per IP")
+	flag.IntVar(&task.Retries, "dr", 1, "Jumlah percobaan ulang unduh")
+	flag.IntVar(&retryWait, "drw", 500, "Jeda percobaan ulang unduh")
+	flag.BoolVar(&task.MeasureLink, "dpl", false, "Ukur kapasitas tautan")
+	flag.BoolVar(&task.Verify, "verify", false, "Verifikasi respons unduh")
+	flag.Int64Var(&task.VerifySize, "verify-size", 0, "Content-Length yang diharapkan")
+	flag.StringVar(&task.VerifySHA256, "verify-sha256", "", "Checksum SHA-256 yang diharapkan")
+	flag.StringVar(&task.VerifyCertName, "verify-cert", "", "Nama sertifikat TLS yang diharapkan")
+	flag.BoolVar(&task.Upload, "up", false, "Aktifkan pengujian unggah")
+	flag.StringVar(&task.UploadURL, "up-url", "https://speed.cloudflare.com/__up", "Alamat pengujian unggah")
+	flag.StringVar(&utils.SpeedSortKey, "speed-sort", "download", "Dasar pengurutan kecepatan")
+	flag.StringVar(&utils.SortKey, "sort", "", "Dasar pengurutan")
+	flag.StringVar(&scoreWeights, "score-weights", "", "Bobot skor gabungan")
+	flag.StringVar(&dataBudget, "data-budget", "", "Batas data pengujian")
 	flag.IntVar(&task.TCPPort, "tp", 443, "Port pengujian yang ditentukan")
-	flag.StringVar(&task.URL, "url", "https://cf.xiu2.xyz/url", "Alamat pengujian yang ditentukan")
+	flag.Var((*stringList)(&task.URLs), "url", "Alamat pengujian yang ditentukan")
+	flag.BoolVar(&task.SkipPreflight, "skip-preflight", false, "Lewati pemeriksaan awal")
 
 	flag.BoolVar(&task.Httping, "httping", false, "Ganti mode pengujian")
-	flag.IntVar(&task.HttpingStatusCode, "httping-code", 0, "Kode status yang valid")
+	flag.StringVar(&task.HttpingStatusCode, "httping-code", "", "Kode status yang valid")
+	flag.Var((*stringList)(&task.HttpingHeaders), "httping-header", "Header respons yang wajib ada")
+	flag.StringVar(&task.HttpingBody, "httping-body", "", "Substring body respons yang wajib ada")
+	flag.StringVar(&task.HttpingBodyRegexp, "httping-body-regex", "", "Regex body respons yang wajib cocok")
 	flag.StringVar(&task.HttpingCFColo, "cfcolo", "", "Cocokkan lokasi tertentu")
+	flag.StringVar(&task.ColoRegion, "region", "", "Pilih colo berdasarkan wilayah")
+	flag.StringVar(&task.ColoCountry, "country", "", "Pilih colo berdasarkan negara")
+	flag.StringVar(&task.ColoExclude, "cfcolo-exclude", "", "Kecualikan lokasi tertentu")
+	flag.StringVar(&utils.LocationFile, "locations", "locations.json", "File data lokasi")
+	flag.BoolVar(&task.ColoDetect, "colo", false, "Deteksi colo")
+	flag.StringVar(&task.TraceURL, "trace-url", "", "Alamat endpoint trace")
+	flag.StringVar(&task.ProviderName, "provider", "cloudflare", "Penyedia CDN")
+	flag.BoolVar(&task.FetchIP, "fetch-ip", false, "Unduh rentang IP resmi")
 
 	flag.IntVar(&maxDelay, "tl", 9999, "Batas atas latensi rata-rata")
 	flag.IntVar(&minDelay, "tll", 0, "Batas bawah latensi rata-rata")
 	flag.Float64Var(&maxLossRate, "tlr", 1, "Batas atas tingkat kehilangan paket")
 	flag.Float64Var(&task.MinSpeed, "sl", 0, "Batas bawah kecepatan unduh")
+	flag.Float64Var(&task.AbortRatio, "sla", 0.5, "Rasio penghentian awal")
+	flag.IntVar(&maxLoadedDelta, "tlb", 0, "Batas atas kenaikan latensi saat beban")
+	flag.Float64Var(&task.MinUploadSpeed, "ul", 0, "Batas bawah kecepatan unggah")
+	flag.StringVar(&utils.FilterExpr, "filter", "", "Ekspresi filter hasil")
 
 	flag.IntVar(&utils.PrintNum, "p", 10, "Jumlah hasil yang ditampilkan")
 	flag.StringVar(&task.IPFile, "f", "ip.txt", "File data rentang IP")
 	flag.StringVar(&task.IPText, "ip", "", "Data rentang IP yang ditentukan")
-	flag.StringVar(&utils.Output, "o", "result.csv", "File hasil output")
+	flag.StringVar(&task.FromResult, "from-result", "", "Uji ulang hasil sebelumnya")
+	flag.IntVar(&task.Top, "top", 0, "Jumlah IP teratas yang diuji ulang")
+	flag.Var((*stringList)(&utils.Outputs), "o", "File hasil output")
+	flag.StringVar(&utils.Format, "format", "csv", "Format file hasil")
+	flag.StringVar(&utils.SeriesOutput, "series", "", "File deret waktu kecepatan unduh")
+	flag.StringVar(&utils.HistoryFile, "history", "", "File riwayat")
+	flag.StringVar(&utils.Network, "network", "", "Nama jaringan")
 
 	flag.BoolVar(&task.Disable, "dd", false, "Nonaktifkan pengujian unduh")
 	flag.BoolVar(&task.TestAll, "allip", false, "Uji semua IP")
@@ -106,11 +255,68 @@ Parameter:
 	if task.MinSpeed > 0 && time.Duration(maxDelay)*time.Millisecond == utils.InputMaxDelay {
 		fmt.Println("[Tips] Saat menggunakan parameter [-sl], disarankan untuk menggunakan parameter [-tl] untuk menghindari pengujian terus-menerus karena jumlah [-dn] tidak mencukupi...")
 	}
+	if len(task.URLs) > 0 {
+		task.URL = task.URLs[0]
+	}
 	utils.InputMaxDelay = time.Duration(maxDelay) * time.Millisecond
 	utils.InputMinDelay = time.Duration(minDelay) * time.Millisecond
 	utils.InputMaxLossRate = float32(maxLossRate)
 	task.Timeout = time.Duration(downloadTime) * time.Second
-	task.HttpingCFColomap = task.MapColoMap()
+	task.RetryWait = time.Duration(retryWait) * time.Millisecond
+	task.MaxLoadedDelta = time.Duration(maxLoadedDelta) * time.Millisecond
+	locations, _ := utils.LoadLocations(utils.LocationFile)
+	if err := task.ParseColoFilter(locations); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		os.Exit(1)
+	}
+	if utils.SpeedSortKey != "download" && utils.SpeedSortKey != "upload" && utils.SpeedSortKey != "sum" {
+		fmt.Printf("[Kesalahan] Dasar pengurutan kecepatan [-speed-sort %s] tidak valid, pilihan: download, upload, sum\n", utils.SpeedSortKey)
+		os.Exit(1)
+	}
+	if err := utils.ParseFilter(); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		os.Exit(1)
+	}
+	if err := utils.ParseSort(scoreWeights); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		os.Exit(1)
+	}
+	if err := task.SetProvider(task.ProviderName); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		os.Exit(1)
+	}
+	if dataBudget != "" {
+		budget, err := utils.ParseByteSize(dataBudget)
+		if err != nil {
+			fmt.Printf("[Kesalahan] %v\n", err)
+			os.Exit(1)
+		}
+		task.DataBudget = budget
+	}
+	if err := task.ParseVerify(); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		os.Exit(1)
+	}
+	if err := utils.ParseOutputs(); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		os.Exit(1)
+	}
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+		runConfig[f.Name] = f.Value.String()
+	})
+	if err := task.LoadPrevious(setFlags["tp"], setFlags["dn"]); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		os.Exit(1)
+	}
+	if !task.CurrentProvider().IsDefaultPort(task.TCPPort) {
+		fmt.Printf("[Tips] Port %d bukan port default penyedia %s, pastikan port tersebut memang dapat digunakan...\n", task.TCPPort, task.ProviderName)
+	}
+	if err := task.ParseHttpingRules(); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		os.Exit(1)
+	}
 
 	if printVersion {
 		println(version)
@@ -130,12 +336,34 @@ func main() {
 
 	fmt.Printf("# XIU2/CloudflareSpeedTest %s \n\n", version)
 
-	// Mulai pengujian latensi + filter latensi/kehilangan paket
-	pingData := task.NewPing().Run().FilterDelay().FilterLossRate()
+	// Periksa alamat pengujian sebelum memulai agar kesalahan [-url] tidak baru diketahui setelah pemindaian selesai
+	if !task.Preflight() {
+		os.Exit(1)
+	}
+	utils.Run = utils.RunInfo{Tool: "cfst", Version: version, Mode: "tcp", Port: task.TCPPort, Provider: task.CurrentProvider().Name, StartedAt: time.Now()}
+	if task.Httping {
+		utils.Run.Mode = "http"
+	}
+	if task.Httping || !task.Disable {
+		utils.Run.URLs = task.URLs
+	}
+
+	// Mulai pengujian latensi + filter latensi/kehilangan paket/[-filter]
+	pingData := task.NewPing().Run().Filter(utils.StagePing)
+	// Deteksi colo (jika diaktifkan) + filter lokasi
+	pingData = task.DetectColo(pingData).Filter(utils.StageColo)
 	// Mulai pengujian unduh
 	speedData := task.TestDownloadSpeed(pingData)
-	utils.ExportCsv(speedData) // Output file
-	speedData.Print()          // Tampilkan hasil
+	// Mulai pengujian unggah (jika diaktifkan)
+	speedData = task.TestUploadSpeed(speedData)
+	task.AttachPrevious(speedData) // Selisih dengan hasil sebelumnya (jika -from-result digunakan)
+	utils.ExportResults(speedData) // Output file
+	utils.ExportSeries(speedData)  // Deret waktu kecepatan unduh
+	if err := utils.AppendHistory(speedData, runConfig); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+	}
+	speedData.Print() // Tampilkan hasil
+	printDataUsed()
 
 	if versionNew != "" {
 		fmt.Printf("\n*** Ditemukan versi baru [%s] ! Silakan periksa [https://github.com/XIU2/CloudflareSpeedTest] untuk memperbarui! ***\n", versionNew)
@@ -143,6 +371,18 @@ func main() {
 	endPrint()
 }
 
+// Tampilkan total data yang digunakan oleh pengujian unduh/unggah
+func printDataUsed() {
+	if task.Disable || utils.NoPrintResult() {
+		return
+	}
+	if task.DataBudget > 0 {
+		fmt.Printf("Data terpakai untuk pengujian: %s (batas: %s)\n", utils.FormatBytes(task.DataUsed()), utils.FormatBytes(task.DataBudget))
+	} else {
+		fmt.Printf("Data terpakai untuk pengujian: %s\n", utils.FormatBytes(task.DataUsed()))
+	}
+}
+
 func endPrint() {
 	if utils.NoPrintResult() {
 		return
@@ -153,6 +393,166 @@ func endPrint() {
 	}
 }
 
+// Subperintah locations: memperbarui file data lokasi
+func locationsCommand(args []string) int {
+	fs := flag.NewFlagSet("locations", flag.ExitOnError)
+	file := fs.String("locations", utils.LocationFile, "File data lokasi")
+	fs.StringVar(&utils.LocationURL, "url", utils.LocationURL, "Alamat data lokasi")
+	if len(args) == 0 || args[0] != "update" {
+		fmt.Println("Penggunaan: cfst locations update [-locations locations.json] [-url https://speed.cloudflare.com/locations]")
+		return 2
+	}
+	_ = fs.Parse(args[1:])
+
+	fmt.Printf("Memperbarui data lokasi dari %s ...\n", utils.LocationURL)
+	added, removed, err := utils.UpdateLocations(*file)
+	if err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		if _, embedded := utils.LoadLocations(*file); embedded {
+			fmt.Println("[Info] File data lokasi tidak ada atau rusak, program akan menggunakan data lokasi bawaan.")
+		}
+		return 1
+	}
+	fmt.Printf("Data lokasi berhasil ditulis ke file %s\n", *file)
+	fmt.Printf("Colo ditambahkan (%d): %s\n", len(added), strings.Join(added, ","))
+	fmt.Printf("Colo dihapus (%d): %s\n", len(removed), strings.Join(removed, ","))
+	return 0
+}
+
+// Subperintah serve: menjalankan server uji kecepatan
+func serveCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "Alamat yang didengarkan")
+	cert := fs.String("cert", "", "File sertifikat TLS")
+	key := fs.String("key", "", "File kunci TLS")
+	colo := fs.String("colo", "LOC", "Placeholder colo untuk /cdn-cgi/trace")
+	_ = fs.Parse(args)
+
+	scheme := "http"
+	if *cert != "" || *key != "" {
+		scheme = "https"
+	}
+	fmt.Printf("Server uji kecepatan berjalan di %s://%s (/__down?bytes=N, /__up, /cdn-cgi/trace)\n", scheme, *addr)
+	if err := server.Run(*addr, *cert, *key, strings.ToUpper(*colo)); err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// Subperintah diff: membandingkan dua file hasil
+func diffCommand(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Output dalam format JSON")
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Println("Penggunaan: cfst diff [-json] lama.csv baru.csv")
+		return 2
+	}
+
+	before, beforeRun, err := utils.ReadResults(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		return 1
+	}
+	after, afterRun, err := utils.ReadResults(fs.Arg(1))
+	if err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		return 1
+	}
+	diff := utils.DiffResults(before, after)
+	diff.Old, diff.New = beforeRun, afterRun
+	if *asJSON {
+		b, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Printf("[Kesalahan] %v\n", err)
+			return 1
+		}
+		fmt.Println(string(b))
+		return 0
+	}
+	fmt.Printf("Membandingkan %s (lama, %d IP) dengan %s (baru, %d IP)\n", fs.Arg(0), len(before), fs.Arg(1), len(after))
+	diff.Print()
+	return 0
+}
+
+// Subperintah history: menampilkan riwayat dari file riwayat
+func historyCommand(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	file := fs.String("history", "history.ndjson", "File riwayat")
+	network := fs.String("network", "", "Hanya pengujian dari jaringan ini")
+	last := fs.Int("last", 10, "Jumlah pengujian terakhir")
+	asJSON := fs.Bool("json", false, "Output dalam format JSON")
+	maxDelay := fs.Int("tl", 0, "Batas atas latensi rata-rata (good)")
+	minSpeed := fs.Float64("sl", 0, "Batas bawah kecepatan unduh (good)")
+	usage := "Penggunaan: cfst history [-history history.ndjson] [-network rumah] [-last 10] [-json] [runs | ip 1.1.1.1 | colo SIN | good [-tl 200] [-sl 5]]"
+	_ = fs.Parse(args)
+	query := append([]string(nil), fs.Args()...)
+	if len(query) > 0 && query[0] == "good" { // Parameter filter setelah good
+		_ = fs.Parse(query[1:])
+		query = append([]string{"good"}, fs.Args()...)
+	}
+	if len(query) == 0 {
+		query = []string{"runs"}
+	}
+
+	runs, err := utils.ReadHistory(*file, *network)
+	if err != nil {
+		fmt.Printf("[Kesalahan] %v\n", err)
+		return 1
+	}
+	runs = utils.LastRuns(runs, *last)
+	if len(runs) == 0 {
+		fmt.Printf("[Info] Tidak ada pengujian dalam file riwayat %s\n", *file)
+		return 0
+	}
+
+	var result interface{}
+	var show func()
+	switch {
+	case query[0] == "runs" && len(query) == 1:
+		result, show = runs, func() { utils.PrintHistoryRuns(runs) }
+	case query[0] == "ip" && len(query) == 2:
+		points := utils.IPHistory(runs, query[1])
+		result, show = points, func() { utils.PrintHistoryPoints(points, false) }
+	case query[0] == "colo" && len(query) == 2:
+		points := utils.ColoHistory(runs, strings.ToUpper(query[1]))
+		result, show = points, func() { utils.PrintHistoryPoints(points, true) }
+	case query[0] == "good" && len(query) == 1:
+		good := utils.ConsistentIPs(runs, time.Duration(*maxDelay)*time.Millisecond, *minSpeed*1024*1024)
+		result, show = good, func() {
+			fmt.Printf("IP yang ada dalam hasil %d pengujian terakhir: %d\n", len(runs), len(good))
+			utils.PrintConsistentIPs(good)
+		}
+	default:
+		fmt.Println(usage)
+		return 2
+	}
+	if *asJSON {
+		b, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Printf("[Kesalahan] %v\n", err)
+			return 1
+		}
+		fmt.Println(string(b))
+		return 0
+	}
+	show()
+	return 0
+}
+
+// Parameter yang dapat digunakan berkali-kali, contoh: -httping-header A -httping-header B
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 // Periksa pembaruan
 func checkUpdate() {
 	timeout := 10 * time.Second
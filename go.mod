@@ -1,8 +1,5 @@
 module github.com/SonzaiEkkusu/Proxy-Finder
 
-go 1.14
+go 1.16
 
-require (
-	github.com/VividCortex/ewma v1.1.1
-	github.com/cheggaaa/pb/v3 v3.0.4
-)
+require github.com/cheggaaa/pb/v3 v3.0.4
@@ -3,12 +3,9 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"encoding/csv"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
@@ -19,7 +16,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
 )
 
 const (
@@ -29,13 +29,19 @@ const (
 )
 
 var (
-	File         = flag.String("file", "ip.txt", "Nama file alamat IP")                                  // Nama file alamat IP
-	outFile      = flag.String("outfile", "ip.csv", "Nama file output")                                  // Nama file output
-	defaultPort  = flag.Int("port", 443, "Port")                                                         // Port
-	maxThreads   = flag.Int("max", 100, "Jumlah maksimum goroutine permintaan bersamaan")                // Jumlah maksimum goroutine
+	File         = flag.String("file", "ip.txt", "Nama file alamat IP")                                                           // Nama file alamat IP
+	outFile      = flag.String("outfile", "ip.csv", "Nama file output (.csv, .json atau .ndjson)")                                // Nama file output
+	defaultPort  = flag.Int("port", 443, "Port")                                                                                  // Port
+	maxThreads   = flag.Int("max", 100, "Jumlah maksimum goroutine permintaan bersamaan")                                         // Jumlah maksimum goroutine
 	speedTest    = flag.Int("speedtest", 5, "Jumlah goroutine uji kecepatan unduh, setel ke 0 untuk menonaktifkan uji kecepatan") // Jumlah goroutine uji kecepatan unduh
-	speedTestURL = flag.String("url", "speed.cloudflare.com/__down?bytes=50000000", "URL file uji kecepatan") // URL file uji kecepatan
-	enableTLS    = flag.Bool("tls", true, "Apakah mengaktifkan TLS")                                     // Apakah mengaktifkan TLS
+	speedTestURL = flag.String("url", "speed.cloudflare.com/__down?bytes=50000000", "URL file uji kecepatan")                     // URL file uji kecepatan
+	enableTLS    = flag.Bool("tls", true, "Apakah mengaktifkan TLS")                                                              // Apakah mengaktifkan TLS
+	dataBudget   = flag.String("data-budget", "", "Batas total data uji kecepatan, contoh: 100MB (default tidak dibatasi)")       // Batas data untuk data seluler terbatas (Termux)
+)
+
+var (
+	budget   int64 // Batas total data uji kecepatan dalam byte, 0 berarti tidak dibatasi
+	dataUsed int64 // Total data yang telah digunakan oleh uji kecepatan
 )
 
 type result struct {
@@ -43,8 +49,8 @@ type result struct {
 	port        int           // Port
 	dataCenter  string        // Pusat data
 	region      string        // Wilayah
+	country     string        // Negara
 	city        string        // Kota
-	latency     string        // Latensi
 	tcpDuration time.Duration // Latensi permintaan TCP
 }
 
@@ -53,13 +59,23 @@ type speedtestresult struct {
 	downloadSpeed float64 // Kecepatan unduh
 }
 
-type location struct {
-	Iata   string  `json:"iata"`
-	Lat    float64 `json:"lat"`
-	Lon    float64 `json:"lon"`
-	Cca2   string  `json:"cca2"`
-	Region string  `json:"region"`
-	City   string  `json:"city"`
+// Mengubah hasil menjadi skema hasil bersama dengan pemindai (cfst), agar file hasil dapat dibaca oleh alat yang sama
+func (res speedtestresult) toData() utils.CloudflareIPData {
+	speed := res.downloadSpeed * 1024 // kB/s menjadi byte/detik
+	return utils.CloudflareIPData{
+		PingData: &utils.PingData{
+			IP:      &net.IPAddr{IP: net.ParseIP(res.ip)},
+			Port:    res.port,
+			TLS:     *enableTLS,
+			Delay:   res.tcpDuration,
+			Colo:    res.dataCenter,
+			City:    res.city,
+			Country: res.country,
+			Region:  res.region,
+		},
+		DownloadSpeed: speed,
+		Download:      utils.SpeedStats{Average: speed, Sustained: speed},
+	}
 }
 
 // Mencoba meningkatkan batas deskriptor file
@@ -76,6 +92,13 @@ func increaseMaxOpenFiles() {
 
 func main() {
 	flag.Parse()
+	if *dataBudget != "" {
+		var err error
+		if budget, err = utils.ParseByteSize(*dataBudget); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
 
 	startTime := time.Now()
 	osType := runtime.GOOS
@@ -83,66 +106,14 @@ func main() {
 		increaseMaxOpenFiles()
 	}
 
-	var locations []location
-	if _, err := os.Stat("locations.json"); os.IsNotExist(err) {
-		fmt.Println("File locations.json tidak ada\nSedang mengunduh locations.json dari https://speed.cloudflare.com/locations")
-		resp, err := http.Get("https://speed.cloudflare.com/locations")
-		if err != nil {
-			fmt.Printf("Tidak dapat mengambil JSON dari URL: %v\n", err)
-			return
-		}
-
-		defer resp.Body.Close()
-
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			fmt.Printf("Tidak dapat membaca body respons: %v\n", err)
-			return
-		}
-
-		err = json.Unmarshal(body, &locations)
-		if err != nil {
-			fmt.Printf("Tidak dapat mengurai JSON: %v\n", err)
-			return
-		}
-		file, err := os.Create("locations.json")
-		if err != nil {
-			fmt.Printf("Tidak dapat membuat file: %v\n", err)
-			return
-		}
-		defer file.Close()
-
-		_, err = file.Write(body)
-		if err != nil {
-			fmt.Printf("Tidak dapat menulis ke file: %v\n", err)
-			return
-		}
+	locations, embedded := utils.LoadLocations("locations.json")
+	if embedded {
+		fmt.Println("File locations.json tidak ada atau rusak, menggunakan data lokasi bawaan program")
 	} else {
-		fmt.Println("File locations.json sudah ada, tidak perlu mengunduh ulang")
-		file, err := os.Open("locations.json")
-		if err != nil {
-			fmt.Printf("Tidak dapat membuka file: %v\n", err)
-			return
-		}
-		defer file.Close()
-
-		body, err := ioutil.ReadAll(file)
-		if err != nil {
-			fmt.Printf("Tidak dapat membaca file: %v\n", err)
-			return
-		}
-
-		err = json.Unmarshal(body, &locations)
-		if err != nil {
-			fmt.Printf("Tidak dapat mengurai JSON: %v\n", err)
-			return
-		}
+		fmt.Println("File locations.json sudah ada, menggunakan data lokasi dari file")
 	}
 
-	locationMap := make(map[string]location)
-	for _, loc := range locations {
-		locationMap[loc.Iata] = loc
-	}
+	locationMap := utils.LocationMap(locations)
 
 	ips, err := readIPs(*File)
 	if err != nil {
@@ -252,10 +223,10 @@ func main() {
 					loc, ok := locationMap[dataCenter]
 					if ok {
 						fmt.Printf("Ditemukan IP valid %s dengan informasi lokasi %s dan latensi %d milidetik\n", ip, loc.City, tcpDuration.Milliseconds())
-						resultChan <- result{ip, *defaultPort, dataCenter, loc.Region, loc.City, fmt.Sprintf("%d ms", tcpDuration.Milliseconds()), tcpDuration}
+						resultChan <- result{ip, *defaultPort, dataCenter, loc.Region, loc.Cca2, loc.City, tcpDuration}
 					} else {
 						fmt.Printf("Ditemukan IP valid %s dengan informasi lokasi tidak diketahui, latensi %d milidetik\n", ip, tcpDuration.Milliseconds())
-						resultChan <- result{ip, *defaultPort, dataCenter, "", "", fmt.Sprintf("%d ms", tcpDuration.Milliseconds()), tcpDuration}
+						resultChan <- result{ip, *defaultPort, dataCenter, "", "", "", tcpDuration}
 					}
 				}
 			}
@@ -318,31 +289,23 @@ func main() {
 		})
 	}
 
-	file, err := os.Create(*outFile)
-	if err != nil {
-		fmt.Printf("Tidak dapat membuat file: %v\n", err)
-		return
+	// Tulis hasil dengan skema yang sama dengan pemindai (cfst), format ditentukan dari ekstensi file output
+	data := make([]utils.CloudflareIPData, 0, len(results))
+	for _, res := range results {
+		data = append(data, res.toData())
 	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
+	utils.Outputs = []string{*outFile}
+	utils.Run = utils.RunInfo{Tool: "finder", Mode: "trace", Port: *defaultPort, Provider: "cloudflare", StartedAt: startTime}
 	if *speedTest > 0 {
-		writer.Write([]string{"Alamat IP", "Port", "TLS", "Pusat Data", "Wilayah", "Kota", "Latensi Jaringan", "Kecepatan Unduh"})
-	} else {
-		writer.Write([]string{"Alamat IP", "Port", "TLS", "Pusat Data", "Wilayah", "Kota", "Latensi Jaringan"})
+		utils.Run.URLs = []string{*speedTestURL}
 	}
-	for _, res := range results {
-		if *speedTest > 0 {
-			writer.Write([]string{res.result.ip, strconv.Itoa(res.result.port), strconv.FormatBool(*enableTLS), res.result.dataCenter, res.result.region, res.result.city, res.result.latency, fmt.Sprintf("%.0f kB/s", res.downloadSpeed)})
-		} else {
-			writer.Write([]string{res.result.ip, strconv.Itoa(res.result.port), strconv.FormatBool(*enableTLS), res.result.dataCenter, res.result.region, res.result.city, res.result.latency})
-		}
-	}
-
-	writer.Flush()
+	utils.ExportResults(data)
 	// Membersihkan output
 	fmt.Print("\033[2J")
 	fmt.Printf("Berhasil menulis hasil ke file %s, memakan waktu %d detik\n", *outFile, time.Since(startTime)/time.Second)
+	if *speedTest > 0 {
+		fmt.Printf("Data terpakai untuk uji kecepatan: %s\n", utils.FormatBytes(atomic.LoadInt64(&dataUsed)))
+	}
 }
 
 // Membaca alamat IP dari file
@@ -385,6 +348,12 @@ func inc(ip net.IP) {
 
 // Fungsi uji kecepatan
 func getDownloadSpeed(ip string) float64 {
+	if budget > 0 {
+		if atomic.LoadInt64(&dataUsed) >= budget {
+			fmt.Printf("Batas data %s tercapai, melewati uji kecepatan IP %s\n", utils.FormatBytes(budget), ip)
+			return 0
+		}
+	}
 	var protocol string
 	if *enableTLS {
 		protocol = "https://"
@@ -428,12 +397,49 @@ func getDownloadSpeed(ip string) float64 {
 	}
 	defer resp.Body.Close()
 
-	// Menyalin body respons ke /dev/null dan menghitung kecepatan unduh
-	written, _ := io.Copy(io.Discard, resp.Body)
+	// Menyalin body respons ke /dev/null dan menghitung kecepatan unduh, data dicatat ke penghitung bersama saat dibaca
+	body := &budgetReader{r: resp.Body}
+	written, _ := io.Copy(io.Discard, body)
 	duration := time.Since(startTime)
+	if body.truncated { // Unduhan dipotong oleh batas data, kecepatan parsial tidak diurutkan bersama hasil yang lengkap
+		fmt.Printf("Batas data %s tercapai saat menguji IP %s, hasil parsial dibuang\n", utils.FormatBytes(budget), ip)
+		return 0
+	}
 	speed := float64(written) / duration.Seconds() / 1024
 
 	// Menampilkan hasil
 	fmt.Printf("IP %s port %s kecepatan unduh %.0f kB/s\n", ip, strconv.Itoa(*defaultPort), speed)
 	return speed
 }
+
+// Pembaca body yang mencatat data ke penghitung bersama [-data-budget] saat dibaca. Sebelum setiap pembacaan,
+// kuota dipesan dari sisa batas agar uji kecepatan yang berjalan bersamaan tidak melebihi batas, sisa pesanan yang tidak terpakai dikembalikan
+type budgetReader struct {
+	r         io.Reader
+	truncated bool // Body dipotong karena batas data tercapai
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if budget <= 0 {
+		n, err := b.r.Read(p)
+		atomic.AddInt64(&dataUsed, int64(n))
+		return n, err
+	}
+	var reserved int64
+	for {
+		used := atomic.LoadInt64(&dataUsed)
+		if used >= budget { // Batas data tercapai, hentikan uji kecepatan ini
+			b.truncated = true
+			return 0, io.EOF
+		}
+		if reserved = int64(len(p)); reserved > budget-used {
+			reserved = budget - used
+		}
+		if atomic.CompareAndSwapInt64(&dataUsed, used, used+reserved) {
+			break
+		}
+	}
+	n, err := b.r.Read(p[:reserved])
+	atomic.AddInt64(&dataUsed, int64(n)-reserved)
+	return n, err
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDownBytes = 100 * 1000 * 1000 // Ukuran default /__down jika bytes tidak ditentukan (100 MB)
+	maxDownBytes     = 10 * 1000 * 1000 * 1000
+	chunkSize        = 64 * 1024
+)
+
+var zeroChunk = make([]byte, chunkSize)
+
+// Handler mengembalikan endpoint server uji kecepatan:
+//
+//	/__down?bytes=N   mengirim N byte (kompatibel dengan speed.cloudflare.com/__down)
+//	/__up             menerima body POST dan mengembalikan jumlah byte yang diterima
+//	/cdn-cgi/trace    format yang sama dengan trace Cloudflare, colo diisi dari CF-RAY jika berada di belakang Cloudflare, jika tidak gunakan placeholder colo
+func Handler(colo string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/__down", down)
+	mux.HandleFunc("/__up", up)
+	mux.HandleFunc("/cdn-cgi/trace", func(w http.ResponseWriter, r *http.Request) {
+		trace(w, r, colo)
+	})
+	return mux
+}
+
+// Menjalankan server uji kecepatan, jika certFile dan keyFile diisi maka gunakan HTTPS
+func Run(addr, certFile, keyFile, colo string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           Handler(colo),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if certFile != "" || keyFile != "" {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	}
+	return srv.ListenAndServe()
+}
+
+func down(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	size := int64(defaultDownBytes)
+	if v := r.URL.Query().Get("bytes"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 || n > maxDownBytes {
+			http.Error(w, "invalid bytes", http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	for size > 0 {
+		n := int64(chunkSize)
+		if size < n {
+			n = size
+		}
+		if _, err := w.Write(zeroChunk[:n]); err != nil {
+			return
+		}
+		size -= n
+	}
+}
+
+func up(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := io.Copy(io.Discard, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintf(w, "received=%d\n", n)
+}
+
+func trace(w http.ResponseWriter, r *http.Request, colo string) {
+	ip := r.Header.Get("CF-Connecting-IP") // Alamat klien asli jika berada di belakang Cloudflare
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	if ray := r.Header.Get("CF-RAY"); ray != "" { // Contoh cf-ray: 7bd32409eda7b020-SJC
+		if i := strings.LastIndexByte(ray, '-'); i >= 0 {
+			colo = ray[i+1:]
+		}
+	}
+	scheme, tlsVersion := "http", "off"
+	if r.TLS != nil {
+		scheme, tlsVersion = "https", tlsVersionName(r.TLS.Version)
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" { // Di belakang proxy, gunakan skema dari sisi klien
+		scheme = proto
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintf(w, "h=%s\nip=%s\nts=%.3f\nvisit_scheme=%s\nuag=%s\ncolo=%s\nhttp=%s\ntls=%s\n",
+		r.Host, ip, float64(time.Now().UnixNano())/1e9, scheme, r.UserAgent(), colo, strings.ToLower(r.Proto), tlsVersion)
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLSv1"
+	case tls.VersionTLS11:
+		return "TLSv1.1"
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	}
+	return "unknown"
+}
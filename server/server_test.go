@@ -0,0 +1,103 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	ts := httptest.NewServer(Handler("LOC"))
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		ray  string
+		want string
+	}{
+		{"placeholder", "", "colo=LOC\n"},
+		{"cf-ray", "7bd32409eda7b020-SJC", "colo=SJC\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+"/cdn-cgi/trace", nil)
+			if tt.ray != "" {
+				req.Header.Set("CF-RAY", tt.ray)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if !strings.Contains(string(body), tt.want) {
+				t.Errorf("trace = %q, want %q", body, tt.want)
+			}
+			if !strings.Contains(string(body), "ip=127.0.0.1\n") {
+				t.Errorf("trace = %q, want ip=127.0.0.1", body)
+			}
+		})
+	}
+}
+
+func TestDown(t *testing.T) {
+	ts := httptest.NewServer(Handler("LOC"))
+	defer ts.Close()
+
+	tests := []struct {
+		method string
+		query  string
+		status int
+		length int64
+	}{
+		{http.MethodGet, "?bytes=0", http.StatusOK, 0},
+		{http.MethodGet, "?bytes=200000", http.StatusOK, 200000},
+		{http.MethodHead, "?bytes=5000", http.StatusOK, 0},
+		{http.MethodGet, "?bytes=-1", http.StatusBadRequest, -1},
+		{http.MethodGet, "?bytes=abc", http.StatusBadRequest, -1},
+		{http.MethodGet, "?bytes=20000000000", http.StatusBadRequest, -1},
+		{http.MethodPost, "?bytes=10", http.StatusMethodNotAllowed, -1},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, ts.URL+"/__down"+tt.query, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.query, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.length >= 0 && n != tt.length {
+			t.Errorf("%s %s: body = %d byte, want %d", tt.method, tt.query, n, tt.length)
+		}
+	}
+}
+
+func TestUp(t *testing.T) {
+	ts := httptest.NewServer(Handler("LOC"))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/__up", "application/octet-stream", strings.NewReader(strings.Repeat("x", 123456)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "received=123456\n" {
+		t.Errorf("up = %d %q, want 200 \"received=123456\\n\"", resp.StatusCode, body)
+	}
+
+	resp, err = http.Get(ts.URL + "/__up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /__up status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
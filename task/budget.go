@@ -0,0 +1,27 @@
+package task
+
+import (
+	"sync/atomic"
+)
+
+var (
+	// DataBudget adalah batas total data (byte) untuk semua pengujian unduh dan unggah, 0 berarti tidak dibatasi (-data-budget)
+	DataBudget int64
+
+	dataUsed int64 // Total data yang telah digunakan oleh pengujian unduh dan unggah
+)
+
+// Catat penggunaan data
+func consumeData(n int64) {
+	atomic.AddInt64(&dataUsed, n)
+}
+
+// Apakah batas data telah tercapai
+func budgetExhausted() bool {
+	return DataBudget > 0 && atomic.LoadInt64(&dataUsed) >= DataBudget
+}
+
+// Total data yang telah digunakan oleh pengujian unduh dan unggah
+func DataUsed() int64 {
+	return atomic.LoadInt64(&dataUsed)
+}
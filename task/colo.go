@@ -0,0 +1,224 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+const traceTimeout = 2 * time.Second
+
+var (
+	// ColoDetect mendeteksi colo setiap IP melalui endpoint trace setelah pengujian latensi
+	ColoDetect = false
+	// TraceURL mengganti endpoint trace bawaan penyedia (-trace-url)
+	TraceURL string
+
+	// Memilih colo berdasarkan wilayah/negara dari data lokasi, dipisahkan dengan koma
+	ColoRegion  string
+	ColoCountry string
+	// Colo yang dikecualikan, dipisahkan dengan koma
+	ColoExclude string
+
+	coloExcludeMap map[string]bool
+	locationMap    map[string]utils.Location
+
+	traceColoRegexp = regexp.MustCompile(`colo=([A-Z]+)`)
+)
+
+// Apakah langkah deteksi colo perlu dijalankan (diaktifkan dengan -colo, atau otomatis saat filter lokasi atau [-filter] dengan field lokasi digunakan dalam mode TCPing)
+func coloDetectEnabled() bool {
+	return ColoDetect || (!Httping && (coloFilterEnabled() || utils.FilterUsesLocation()))
+}
+
+// Apakah ada filter lokasi (-cfcolo, -region, -country, -cfcolo-exclude)
+func coloFilterEnabled() bool {
+	return HttpingCFColomap != nil || coloExcludeMap != nil
+}
+
+// Mem-parse filter lokasi dari parameter: colo yang dipilih = -cfcolo + colo di -region + colo di -country, dikurangi -cfcolo-exclude
+func ParseColoFilter(locations []utils.Location) error {
+	locationMap = utils.LocationMap(locations)
+	HttpingCFColomap = MapColoMap()
+
+	regions := splitUpper(ColoRegion)
+	countries := splitUpper(ColoCountry)
+	if len(regions) > 0 || len(countries) > 0 {
+		if len(locations) == 0 {
+			return fmt.Errorf("data lokasi [%s] tidak tersedia, tidak dapat memilih colo berdasarkan wilayah/negara", utils.LocationFile)
+		}
+		if HttpingCFColomap == nil {
+			HttpingCFColomap = &sync.Map{}
+		}
+		for _, region := range regions {
+			if n := storeColos(locations, func(loc utils.Location) bool { return strings.ToUpper(loc.Region) == region }); n == 0 {
+				return fmt.Errorf("wilayah [%s] tidak ditemukan, wilayah yang tersedia: %s", region, strings.Join(knownRegions(locations), ", "))
+			}
+		}
+		for _, country := range countries {
+			if n := storeColos(locations, func(loc utils.Location) bool { return strings.ToUpper(loc.Cca2) == country }); n == 0 {
+				return fmt.Errorf("negara [%s] tidak memiliki colo dalam data lokasi, gunakan kode negara dua huruf (contoh: SG,ID)", country)
+			}
+		}
+	}
+
+	if excludes := splitUpper(ColoExclude); len(excludes) > 0 {
+		coloExcludeMap = make(map[string]bool, len(excludes))
+		for _, colo := range excludes {
+			coloExcludeMap[colo] = true
+		}
+	}
+	return nil
+}
+
+// Simpan semua colo yang cocok dengan kondisi ke HttpingCFColomap, kembalikan jumlah yang cocok
+func storeColos(locations []utils.Location, match func(utils.Location) bool) (n int) {
+	for _, loc := range locations {
+		if match(loc) {
+			HttpingCFColomap.Store(strings.ToUpper(loc.Iata), loc.Iata)
+			n++
+		}
+	}
+	return
+}
+
+// Daftar wilayah yang ada di data lokasi, digunakan untuk pesan kesalahan
+func knownRegions(locations []utils.Location) []string {
+	seen := make(map[string]bool)
+	var regions []string
+	for _, loc := range locations {
+		if !seen[loc.Region] {
+			seen[loc.Region] = true
+			regions = append(regions, loc.Region)
+		}
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// Pisahkan dengan koma, ubah menjadi huruf besar, dan buang yang kosong
+func splitUpper(s string) (out []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return
+}
+
+// Lengkapi kota, negara, dan wilayah berdasarkan colo, tidak berlaku untuk penyedia dengan kode lokasi sendiri
+func fillLocation(data *utils.PingData) {
+	if provider.ownCodes {
+		return
+	}
+	if loc, ok := locationMap[data.Colo]; ok {
+		data.City, data.Country, data.Region = loc.City, loc.Cca2, loc.Region
+	}
+}
+
+// Mendeteksi colo setiap IP yang lolos filter tahap pengujian latensi melalui endpoint trace, lalu buang IP yang tidak sesuai dengan daerah yang ditentukan
+func DetectColo(ipSet utils.PingDelaySet) (data utils.PingDelaySet) {
+	if !coloDetectEnabled() || len(ipSet) == 0 {
+		return ipSet
+	}
+	fmt.Printf("Mulai deteksi colo (jumlah: %d, alamat: %s)\n", len(ipSet), traceURL())
+
+	var (
+		wg      sync.WaitGroup
+		control = make(chan bool, Routines)
+	)
+	bar := utils.NewBar(len(ipSet), "Colo:", "")
+	for i := range ipSet {
+		if ipSet[i].Colo != "" { // Colo sudah didapatkan (misalnya dari mode HTTPing)
+			bar.Grow(1, "")
+			continue
+		}
+		wg.Add(1)
+		control <- false
+		go func(v *utils.CloudflareIPData) {
+			defer func() {
+				<-control
+				wg.Done()
+			}()
+			v.Colo = traceColo(v.IP)
+			fillLocation(v.PingData)
+			bar.Grow(1, v.Colo)
+		}(&ipSet[i])
+	}
+	wg.Wait()
+	bar.Done()
+
+	for _, v := range ipSet {
+		if coloFilterEnabled() && (v.Colo == "" || !coloAllowed(v.Colo)) { // Tidak sesuai dengan daerah yang ditentukan
+			continue
+		}
+		data = append(data, v)
+	}
+	return
+}
+
+// Alamat trace yang digunakan, jika penyedia tidak memiliki endpoint trace maka gunakan [-url] dan baca colo dari header respons
+// Jika port pengujian adalah port tanpa TLS maka gunakan http://
+func traceURL() string {
+	trace := TraceURL
+	if trace == "" {
+		trace = provider.TraceURL
+	}
+	if trace == "" {
+		return URL
+	}
+	u, err := url.Parse(trace)
+	if err != nil {
+		return trace
+	}
+	if provider.isPlainPort(TCPPort) {
+		u.Scheme = "http"
+	}
+	return u.String()
+}
+
+// Mengunjungi endpoint trace melalui IP yang ditentukan dan mengembalikan kode tiga huruf colo
+func traceColo(ip *net.IPAddr) string {
+	trace := traceURL()
+	hc := http.Client{
+		Timeout:   traceTimeout,
+		Transport: &http.Transport{DialContext: getDialContext(ip)},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // Cegah pengalihan
+		},
+	}
+	requ, err := http.NewRequest(http.MethodGet, trace, nil)
+	if err != nil {
+		return ""
+	}
+	requ.Header.Set("User-Agent", "Mozilla/5.0")
+	requ.Close = true
+	resp, err := hc.Do(requ)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if TraceURL == "" && provider.TraceURL == "" { // Penyedia tanpa endpoint trace, baca colo dari header respons
+		return provider.Colo(resp.Header)
+	}
+	if resp.StatusCode != 200 {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return ""
+	}
+	if matches := traceColoRegexp.FindSubmatch(body); len(matches) > 1 {
+		return string(matches[1])
+	}
+	return ""
+}
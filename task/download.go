@@ -6,13 +6,12 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
-
-	"github.com/VividCortex/ewma"
 )
 
 const (
@@ -22,21 +21,45 @@ const (
 	defaultDisableDownload         = false
 	defaultTestNum                 = 10
 	defaultMinSpeed        float64 = 0.0
+	defaultParallel                = 1
+	maxParallel                    = 100
+	defaultConnections             = 1
+	maxConnections                 = 32
+	linkSaturation                 = 0.8 // Jika kecepatan gabungan kurang dari 80% dari perkiraan, tautan dianggap jenuh
+	defaultAbortRatio              = 0.5
+	minDownloadSize                = 100 * 1024 // Respons unduh dengan Content-Length di bawah 100 KB dianggap bukan file uji (misalnya halaman login/captive portal)
 )
 
 var (
-	URL     = defaultURL
+	URL = defaultURL
+	// URLs adalah daftar alamat unduh (-url dapat digunakan berkali-kali), IP bergiliran menggunakan alamat yang berbeda,
+	// jika alamat gagal (bukan 200 atau terlalu kecil) maka gunakan alamat berikutnya; URLs[0] juga digunakan untuk HTTPing
+	URLs    []string
 	Timeout = defaultTimeout
 	Disable = defaultDisableDownload
 
 	TestCount = defaultTestNum
 	MinSpeed  = defaultMinSpeed
+
+	// Parallel adalah jumlah pengujian unduh yang dijalankan bersamaan (-dp)
+	Parallel = defaultParallel
+	// MeasureLink mengukur kapasitas tautan sebelum pengujian unduh paralel (-dpl)
+	MeasureLink = false
+	// Connections adalah jumlah koneksi bersamaan ke IP yang sama dalam satu pengujian unduh (-dc)
+	Connections = defaultConnections
+	// AbortRatio menghentikan pengujian unduh lebih awal jika setelah waktu pemanasan kecepatan stabil masih di bawah
+	// MinSpeed * AbortRatio (-sla), 0 untuk menonaktifkan, hanya berlaku jika [-sl] digunakan
+	AbortRatio = defaultAbortRatio
 )
 
 func checkDownloadDefault() {
-	if URL == "" {
-		URL = defaultURL
+	if len(URLs) == 0 {
+		if URL == "" {
+			URL = defaultURL
+		}
+		URLs = []string{URL}
 	}
+	URL = URLs[0]
 	if Timeout <= 0 {
 		Timeout = defaultTimeout
 	}
@@ -46,6 +69,19 @@ func checkDownloadDefault() {
 	if MinSpeed <= 0.0 {
 		MinSpeed = defaultMinSpeed
 	}
+	if Parallel <= 0 {
+		Parallel = defaultParallel
+	} else if Parallel > maxParallel {
+		Parallel = maxParallel
+	}
+	if AbortRatio < 0 || AbortRatio > 1 {
+		AbortRatio = defaultAbortRatio
+	}
+	if Connections <= 0 {
+		Connections = defaultConnections
+	} else if Connections > maxConnections {
+		Connections = maxConnections
+	}
 }
 
 func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSet) {
@@ -65,7 +101,17 @@ func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSe
 		TestCount = testNum
 	}
 
-	fmt.Printf("Mulai tes kecepatan unduh (batas bawah: %.2f MB/s, jumlah: %d, antrian: %d)\n", MinSpeed, TestCount, testNum)
+	preflightURLs(ipSet[0].IP)
+
+	workers := Parallel
+	if workers > testNum {
+		workers = testNum
+	}
+	if workers > 1 && MeasureLink {
+		checkLinkSaturation(ipSet[0].IP, workers)
+	}
+
+	fmt.Printf("Mulai tes kecepatan unduh (batas bawah: %.2f MB/s, jumlah: %d, antrian: %d, paralel: %d, koneksi: %d, alamat: %d)\n", MinSpeed, TestCount, testNum, workers, Connections, len(URLs))
 	// Mengatur panjang progress bar tes kecepatan unduh dan tes ping agar sesuai (obsesif-kompulsif)
 	bar_a := len(strconv.Itoa(len(ipSet)))
 	bar_b := "     "
@@ -73,27 +119,139 @@ func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSe
 		bar_b += " "
 	}
 	bar := utils.NewBar(TestCount, bar_b, "")
-	for i := 0; i < testNum; i++ {
-		speed := downloadHandler(ipSet[i].IP)
-		ipSet[i].DownloadSpeed = speed
-		// Setelah setiap IP diuji kecepatan unduhnya, filter hasil berdasarkan [batas bawah kecepatan unduh]
-		if speed >= MinSpeed*1024*1024 {
-			bar.Grow(1, "")
-			speedSet = append(speedSet, ipSet[i]) // Jika lebih tinggi dari batas bawah kecepatan unduh, tambahkan ke array baru
-			if len(speedSet) == TestCount {       // Setelah cukup jumlah IP yang memenuhi syarat (jumlah tes kecepatan unduh -dn), keluar dari loop
-				break
+	var (
+		wg   sync.WaitGroup
+		m    sync.Mutex
+		next int // Indeks IP berikutnya dalam antrian
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				m.Lock()
+				// Setelah cukup jumlah IP yang memenuhi syarat (jumlah tes kecepatan unduh -dn) atau antrian habis, berhenti mengambil IP baru
+				// Jika batas data (-data-budget) tercapai, pengujian unduh berikutnya juga dihentikan
+				if next >= testNum || len(speedSet) >= TestCount || budgetExhausted() {
+					m.Unlock()
+					return
+				}
+				i := next
+				next++
+				m.Unlock()
+
+				// Alamat unduh bergiliran untuk menyebarkan beban, kecepatan unduh utama adalah kecepatan stabil
+				result := downloadWithFallback(ipSet[i].IP, i%len(URLs))
+				speed := result.stats.Sustained
+				ipSet[i].DownloadSpeed = speed
+				ipSet[i].Download = result.stats
+				ipSet[i].ConnSpeeds = result.connSpeeds
+				ipSet[i].VerifyError = result.invalid
+				ipSet[i].Aborted = result.aborted
+				ipSet[i].DownloadURL = result.url
+				ipSet[i].DownloadError = result.failure
+				ipSet[i].LoadedDelay = result.loaded
+				ipSet[i].IdleDelay = result.idle
+				// Setelah setiap IP diuji kecepatan unduhnya, filter hasil berdasarkan [batas bawah kecepatan unduh] dan [-filter], IP yang gagal verifikasi
+				// atau latensinya naik melebihi [-tlb] saat beban tidak dianggap memenuhi syarat
+				if result.invalid == "" && speed >= MinSpeed*1024*1024 && loadedDeltaAllowed(ipSet[i]) && utils.FilterAllows(&ipSet[i], utils.StageDownload) {
+					m.Lock()
+					if len(speedSet) < TestCount {
+						bar.Grow(1, "")
+						speedSet = append(speedSet, ipSet[i]) // Jika lebih tinggi dari batas bawah kecepatan unduh, tambahkan ke array baru
+					}
+					m.Unlock()
+				}
 			}
-		}
+		}()
 	}
+	wg.Wait()
 	bar.Done()
+	printVerifySummary(ipSet[:testNum])
+	printAbortSummary(ipSet[:testNum])
+	printFailureSummary(ipSet[:testNum])
+	printLoadedSummary(ipSet[:testNum])
+	if budgetExhausted() {
+		fmt.Printf("\n[Info] Batas data %s tercapai (terpakai %s), pengujian unduh dihentikan dan IP yang belum diuji dilewati.\n", utils.FormatBytes(DataBudget), utils.FormatBytes(DataUsed()))
+	}
 	if len(speedSet) == 0 { // Tidak ada data yang memenuhi batas kecepatan, kembalikan semua data tes
 		speedSet = utils.DownloadSpeedSet(ipSet)
 	}
 	// Urutkan berdasarkan kecepatan
-	sort.Sort(speedSet)
+	speedSet.Sort()
 	return
 }
 
+// Mengukur kapasitas tautan melalui IP terbaik: bandingkan satu koneksi dengan n koneksi bersamaan (masing-masing satu koneksi, terlepas dari [-dc]),
+// jika kecepatan gabungan jauh di bawah n kali kecepatan satu koneksi, pengujian paralel akan saling berebut tautan dan hasil per IP menjadi tidak akurat.
+// Pengukuran ini menjalankan 1+n pengujian unduh tambahan selama [-dt] yang ikut dihitung dalam [-data-budget]
+func checkLinkSaturation(ip *net.IPAddr, n int) {
+	fmt.Printf("Mengukur kapasitas tautan melalui %s (1 koneksi, lalu %d koneksi bersamaan)...\n", ip.String(), n)
+	single := downloadHandler(ip, URLs[0], 1).stats.Sustained
+	if single <= 0 {
+		fmt.Println("[Info] Gagal mengukur kapasitas tautan, melewati pemeriksaan.")
+		return
+	}
+	var (
+		wg    sync.WaitGroup
+		m     sync.Mutex
+		total float64
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			speed := downloadHandler(ip, URLs[0], 1).stats.Sustained
+			m.Lock()
+			total += speed
+			m.Unlock()
+		}()
+	}
+	wg.Wait()
+	fmt.Printf("Kapasitas tautan: 1 koneksi %.2f MB/s, %d koneksi %.2f MB/s\n", single/1024/1024, n, total/1024/1024)
+	if total < single*float64(n)*linkSaturation {
+		fmt.Printf("[Peringatan] %d pengujian paralel akan menjenuhkan tautan (setiap IP hanya mendapat sekitar %.2f MB/s), hasil per IP akan terdistorsi, disarankan untuk menurunkan [-dp]...\n", n, total/float64(n)/1024/1024)
+	}
+}
+
+// Memeriksa semua alamat unduh melalui IP terbaik sebelum pengujian, alamat yang gagal (bukan 200 atau terlalu kecil) dibuang
+func preflightURLs(ip *net.IPAddr) {
+	if len(URLs) <= 1 {
+		return
+	}
+	var usable []string
+	for _, url := range URLs {
+		response, invalid, failure := openDownload(ip, url)
+		if response == nil {
+			if invalid == "" {
+				invalid = failure
+			}
+			fmt.Printf("[Peringatan] Alamat unduh %s tidak dapat digunakan (%s), alamat ini dilewati.\n", url, invalid)
+			continue
+		}
+		response.Body.Close()
+		usable = append(usable, url)
+	}
+	if len(usable) == 0 { // Semua alamat gagal, mungkin masalah IP terbaik, tetap gunakan semua alamat
+		fmt.Println("[Peringatan] Semua alamat unduh gagal diperiksa melalui IP terbaik, tetap menggunakan semua alamat.")
+		return
+	}
+	URLs = usable
+}
+
+// Menampilkan jumlah IP yang pengujian unduhnya dihentikan lebih awal
+func printAbortSummary(ipSet utils.PingDelaySet) {
+	aborted := 0
+	for _, v := range ipSet {
+		if v.Aborted {
+			aborted++
+		}
+	}
+	if aborted > 0 {
+		fmt.Printf("\n[Info] %d IP dihentikan lebih awal karena kecepatan stabil di bawah %.2f MB/s setelah %.1f detik (kecepatan yang dicatat adalah kecepatan parsial).\n", aborted, MinSpeed*AbortRatio, abortCheckAfter().Seconds())
+	}
+}
+
 func getDialContext(ip *net.IPAddr) func(ctx context.Context, network, address string) (net.Conn, error) {
 	var fakeSourceAddr string
 	if isIPv4(ip.String()) {
@@ -106,9 +264,31 @@ func getDialContext(ip *net.IPAddr) func(ctx context.Context, network, address s
 	}
 }
 
-// Mengembalikan kecepatan unduh
-func downloadHandler(ip *net.IPAddr) float64 {
+// Hasil pengujian unduh satu IP
+type downloadResult struct {
+	stats      utils.SpeedStats // Statistik kecepatan gabungan (lihat throughputEstimator)
+	connSpeeds []float64        // Kecepatan rata-rata setiap koneksi (-dc)
+	invalid    string           // Alasan respons gagal verifikasi, kosong jika valid
+	url        string           // Alamat unduh yang menghasilkan pengukuran ini
+	aborted    bool             // Pengujian dihentikan lebih awal karena kecepatan jauh di bawah [-sl]
+	failure    string           // Alasan kegagalan (dial, tls, timeout, reset, status N, size), kosong jika berhasil
+	loaded     time.Duration    // Median latensi saat beban (lihat latencyProbe)
+	idle       time.Duration    // Median latensi tanpa beban sebelum koneksi unduh dibuka (lihat idleLatency)
+}
+
+// Waktu mulai pemeriksaan penghentian lebih awal: setelah waktu pemanasan, paling lambat setengah durasi pengujian
+func abortCheckAfter() time.Duration {
+	if Timeout/2 < maxWarmUp {
+		return Timeout / 2
+	}
+	return maxWarmUp
+}
+
+// Membuka satu koneksi unduh ke IP dan alamat yang ditentukan, mengembalikan respons yang siap dibaca,
+// alasan kegagalan verifikasi jika respons tidak lolos verifikasi, atau alasan kegagalan koneksi
+func openDownload(ip *net.IPAddr, url string) (*http.Response, string, string) {
 	client := &http.Client{
+		// Setiap koneksi menggunakan Transport sendiri agar tidak berbagi koneksi TCP yang sama
 		Transport: &http.Transport{DialContext: getDialContext(ip)},
 		Timeout:   Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -121,63 +301,198 @@ func downloadHandler(ip *net.IPAddr) float64 {
 			return nil
 		},
 	}
-	req, err := http.NewRequest("GET", URL, nil)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return 0.0
+		return nil, "", failOther
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
 
 	response, err := client.Do(req)
 	if err != nil {
-		return 0.0
+		return nil, "", classifyError(err)
 	}
-	defer response.Body.Close()
 	if response.StatusCode != 200 {
-		return 0.0
+		response.Body.Close()
+		return nil, "", statusFailure(response.StatusCode)
+	}
+	if response.ContentLength >= 0 && response.ContentLength < minDownloadSize { // Respons terlalu kecil untuk pengujian unduh
+		response.Body.Close()
+		return nil, "", failSize
+	}
+	if invalid := verifyResponse(response); invalid != "" {
+		response.Body.Close()
+		return nil, invalid, ""
+	}
+	return response, "", ""
+}
+
+// Menguji kecepatan unduh satu IP, alamat dicoba bergiliran mulai dari URLs[first],
+// jika alamat gagal (kode status selain 200 atau file terlalu kecil, termasuk setelah percobaan ulang) maka gunakan alamat berikutnya
+func downloadWithFallback(ip *net.IPAddr, first int) (result downloadResult) {
+	for i := 0; i < len(URLs); i++ {
+		result = downloadWithRetry(ip, URLs[(first+i)%len(URLs)])
+		// Pengujian berhasil dijalankan, respons gagal verifikasi atau koneksi gagal (masalah IP, bukan alamat)
+		if result.url != "" || result.invalid != "" || !isURLFailure(result.failure) {
+			return
+		}
+	}
+	return
+}
+
+// Menguji kecepatan unduh satu IP melalui conns koneksi bersamaan ([-dc] untuk pengujian IP)
+func downloadHandler(ip *net.IPAddr, url string, conns int) (result downloadResult) {
+	// Ukur latensi tanpa beban dengan probe yang sama sebelum tautan dijenuhkan, sebagai dasar kenaikan latensi saat beban
+	result.idle = idleLatency(ip)
+
+	// Buka semua koneksi secara bersamaan, koneksi yang gagal diabaikan
+	responses := make([]*http.Response, conns)
+	invalids := make([]string, conns)
+	failures := make([]string, conns)
+	var wg sync.WaitGroup
+	for c := range responses {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			responses[c], invalids[c], failures[c] = openDownload(ip, url)
+		}(c)
+	}
+	wg.Wait()
+	for _, invalid := range invalids {
+		if invalid != "" { // Satu koneksi saja yang gagal verifikasi sudah cukup untuk menganggap IP ini tidak valid
+			for _, response := range responses {
+				if response != nil {
+					response.Body.Close()
+				}
+			}
+			result.invalid = invalid
+			return
+		}
 	}
+	opened := 0
+	for _, response := range responses {
+		if response != nil {
+			opened++
+		}
+	}
+	if opened == 0 { // Semua koneksi gagal, gunakan alasan kegagalan koneksi pertama
+		result.failure = failures[0]
+		return
+	}
+	result.url = url
+
 	timeStart := time.Now()           // Waktu mulai (sekarang)
 	timeEnd := timeStart.Add(Timeout) // Tambahkan waktu tes kecepatan unduh untuk mendapatkan waktu selesai
 
-	contentLength := response.ContentLength // Ukuran file
-	buffer := make([]byte, bufferSize)
-
 	var (
-		contentRead     int64 = 0
-		timeSlice             = Timeout / 100
-		timeCounter           = 1
-		lastContentRead int64 = 0
+		contentRead int64 // Penghitung byte gabungan semua koneksi
+		connRead    = make([]int64, len(responses))
+		closing     int32           // Bernilai 1 setelah pengujian selesai dan body ditutup, kesalahan baca setelahnya bukan kegagalan
+		timeSlice   = Timeout / 100 // Interval pengambilan sampel
+		done        = make(chan struct{})
 	)
 
-	var nextTime = timeStart.Add(timeSlice * time.Duration(timeCounter))
-	e := ewma.NewMovingAverage()
-
-	// Loop untuk menghitung, jika file selesai diunduh (keduanya sama), keluar dari loop (hentikan tes kecepatan)
-	for contentLength != contentRead {
-		currentTime := time.Now()
-		if currentTime.After(nextTime) {
-			timeCounter++
-			nextTime = timeStart.Add(timeSlice * time.Duration(timeCounter))
-			e.Add(float64(contentRead - lastContentRead))
-			lastContentRead = contentRead
+	// Setiap koneksi membaca body-nya sendiri dan menambahkan jumlah byte ke penghitung gabungan
+	for c, response := range responses {
+		if response == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(c int, response *http.Response) {
+			defer wg.Done()
+			buffer := make([]byte, bufferSize)
+			contentLength := response.ContentLength // Ukuran file
+			payload := newPayloadVerifier()
+			var read int64
+			// Loop untuk menghitung, jika file selesai diunduh (keduanya sama), keluar dari loop (hentikan tes kecepatan)
+			for contentLength != read && time.Now().Before(timeEnd) && !budgetExhausted() {
+				bufferRead, err := response.Body.Read(buffer)
+				read += int64(bufferRead)
+				consumeData(int64(bufferRead))
+				payload.write(buffer[:bufferRead])
+				atomic.AddInt64(&contentRead, int64(bufferRead))
+				atomic.AddInt64(&connRead[c], int64(bufferRead))
+				// Jika terjadi kesalahan (misalnya Timeout) atau file selesai diunduh (termasuk ukuran file tidak diketahui, contentLength == -1), hentikan koneksi ini
+				if err != nil {
+					if err == io.EOF { // Seluruh body diterima, periksa checksum
+						contentLength = read
+					} else if atomic.LoadInt32(&closing) == 0 && classifyError(err) != failTimeout { // Batas waktu adalah akhir pengujian yang normal
+						failures[c] = failReset
+					}
+					break
+				}
+			}
+			if contentLength == read { // Checksum hanya dapat diperiksa jika seluruh file selesai diunduh
+				invalids[c] = payload.check()
+			}
+		}(c, response)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Ukur latensi ke IP yang sama selama tautan dijenuhkan oleh pengujian unduh
+	probe := startLatencyProbe(ip)
+
+	// Ambil sampel penghitung byte gabungan setiap potongan waktu
+	estimator := newThroughputEstimator()
+	ticker := time.NewTicker(timeSlice)
+	defer ticker.Stop()
+	abortSpeed := MinSpeed * 1024 * 1024 * AbortRatio
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			elapsed := time.Since(timeStart)
+			estimator.add(elapsed, atomic.LoadInt64(&contentRead))
+			// Jika melebihi waktu tes kecepatan unduh, keluar dari loop (hentikan tes kecepatan)
+			if time.Now().After(timeEnd) {
+				break loop
+			}
+			// Setelah waktu pemanasan, jika kecepatan stabil jauh di bawah batas bawah kecepatan unduh, IP ini tidak mungkin memenuhi syarat, hentikan lebih awal
+			if abortSpeed > 0 && elapsed >= abortCheckAfter() && estimator.stats().Sustained < abortSpeed {
+				result.aborted = true
+				break loop
+			}
+		case <-done:
+			// Semua koneksi selesai sebelum waktu habis (file selesai diunduh), sampel terakhir dicatat pada waktu selesai yang sebenarnya
+			estimator.add(time.Since(timeStart), atomic.LoadInt64(&contentRead))
+			break loop
+		}
+	}
+	result.stats = estimator.stats()
+	result.loaded = probe.finish()
+
+	// Tutup semua koneksi dan tunggu semua goroutine pembaca selesai
+	atomic.StoreInt32(&closing, 1)
+	for _, response := range responses {
+		if response != nil {
+			response.Body.Close()
 		}
-		// Jika melebihi waktu tes kecepatan unduh, keluar dari loop (hentikan tes kecepatan)
-		if currentTime.After(timeEnd) {
+	}
+	<-done
+	for _, invalid := range invalids {
+		if invalid != "" {
+			result.invalid = invalid
+			result.stats = utils.SpeedStats{}
+			return
+		}
+	}
+
+	for c, response := range responses {
+		if response != nil && failures[c] != "" { // Koneksi terputus di tengah body, kecepatan adalah kecepatan parsial
+			result.failure = failures[c]
 			break
 		}
-		bufferRead, err := response.Body.Read(buffer)
-		if err != nil {
-			if err != io.EOF { // Jika terjadi kesalahan saat mengunduh file (misalnya Timeout), dan bukan karena file sudah selesai diunduh, keluar dari loop (hentikan tes kecepatan)
-				break
-			} else if contentLength == -1 { // File selesai diunduh dan ukuran file tidak diketahui, keluar dari loop (hentikan tes kecepatan), misalnya: https://speed.cloudflare.com/__down?bytes=200000000 jika diunduh dalam 10 detik, dapat menyebabkan hasil tes kecepatan sangat rendah atau bahkan menunjukkan 0.00 (kecepatan unduh terlalu cepat)
-				break
-			}
-			// Dapatkan potongan waktu sebelumnya
-			last_time_slice := timeStart.Add(timeSlice * time.Duration(timeCounter-1))
-			// Jumlah data yang diunduh / (gunakan waktu saat ini - potongan waktu sebelumnya / potongan waktu)
-			e.Add(float64(contentRead-lastContentRead) / (float64(currentTime.Sub(last_time_slice)) / float64(timeSlice)))
+	}
+
+	// Kecepatan rata-rata setiap koneksi
+	result.connSpeeds = make([]float64, 0, opened)
+	for c, response := range responses {
+		if response != nil {
+			result.connSpeeds = append(result.connSpeeds, float64(atomic.LoadInt64(&connRead[c]))/result.stats.Elapsed.Seconds())
 		}
-		contentRead += int64(bufferRead)
 	}
-	return e.Value() / (Timeout.Seconds() / 120)
+	return
 }
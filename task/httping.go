@@ -2,27 +2,171 @@ package task
 
 import (
 	//"crypto/tls"
-	//"fmt"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+const maxHttpingBodySize = 1 << 20 // Batas ukuran body yang dibaca untuk aturan body (1 MB)
+
 var (
 	Httping           bool
-	HttpingStatusCode int
+	HttpingStatusCode string   // Contoh: "200", "2xx,301", "200-299"
+	HttpingHeaders    []string // Contoh: "Server", "Server:cloudflare"
+	HttpingBody       string   // Substring yang wajib ada di body respons
+	HttpingBodyRegexp string   // Regex yang wajib cocok dengan body respons
 	HttpingCFColo     string
 	HttpingCFColomap  *sync.Map
 	OutRegexp         = regexp.MustCompile(`[A-Z]{3}`)
+
+	httpingRules *responseRules
 )
 
-// pingReceived pingTotalTime
-func (p *Ping) httping(ip *net.IPAddr) (int, time.Duration) {
+// Rentang kode status HTTP yang dianggap valid (inklusif)
+type statusRange struct {
+	min, max int
+}
+
+// Header respons yang wajib ada, jika value tidak kosong maka nilainya juga harus mengandung value (tidak peka huruf besar/kecil)
+type headerRule struct {
+	name  string
+	value string
+}
+
+// Kumpulan aturan validasi respons HTTPing
+type responseRules struct {
+	codes      []statusRange
+	headers    []headerRule
+	body       string
+	bodyRegexp *regexp.Regexp
+}
+
+// Mem-parse aturan validasi respons HTTPing dari parameter, harus dipanggil saat program dimulai (sebelum pengujian latensi
+// yang berjalan bersamaan membaca aturan) agar input yang salah langsung ditolak
+func ParseHttpingRules() error {
+	rules := &responseRules{body: HttpingBody}
+	if strings.TrimSpace(HttpingStatusCode) == "" { // Jika kode status HTTP tidak ditentukan, maka default hanya 200, 301, 302 yang dianggap berhasil
+		rules.codes = []statusRange{{200, 200}, {301, 301}, {302, 302}}
+	} else {
+		for _, code := range strings.Split(HttpingStatusCode, ",") {
+			code = strings.TrimSpace(code)
+			if code == "" {
+				continue
+			}
+			r, err := parseStatusRange(code)
+			if err != nil {
+				return err
+			}
+			rules.codes = append(rules.codes, r)
+		}
+		if len(rules.codes) == 0 {
+			return fmt.Errorf("kode status HTTPing [-httping-code %s] tidak valid", HttpingStatusCode)
+		}
+	}
+	for _, h := range HttpingHeaders {
+		name, value := h, ""
+		if i := strings.IndexByte(h, ':'); i >= 0 {
+			name, value = h[:i], h[i+1:]
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return fmt.Errorf("aturan header HTTPing [-httping-header %s] tidak valid, format yang benar: Nama atau Nama:nilai", h)
+		}
+		rules.headers = append(rules.headers, headerRule{name: http.CanonicalHeaderKey(name), value: strings.ToLower(value)})
+	}
+	if HttpingBodyRegexp != "" {
+		re, err := regexp.Compile(HttpingBodyRegexp)
+		if err != nil {
+			return fmt.Errorf("regex body HTTPing [-httping-body-regex %s] tidak valid: %v", HttpingBodyRegexp, err)
+		}
+		rules.bodyRegexp = re
+	}
+	httpingRules = rules
+	return nil
+}
+
+// Mem-parse satu kode status, mendukung "200", "2xx" dan "200-299"
+func parseStatusRange(code string) (statusRange, error) {
+	invalid := fmt.Errorf("kode status HTTPing [%s] tidak valid, gunakan kode 100~599, pola seperti 2xx atau rentang seperti 200-299", code)
+	var r statusRange
+	switch {
+	case len(code) == 3 && strings.HasSuffix(strings.ToLower(code), "xx"):
+		d, err := strconv.Atoi(code[:1])
+		if err != nil {
+			return r, invalid
+		}
+		r = statusRange{d * 100, d*100 + 99}
+	case strings.Contains(code, "-"):
+		parts := strings.SplitN(code, "-", 2)
+		min, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+		max, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err1 != nil || err2 != nil || min > max {
+			return r, invalid
+		}
+		r = statusRange{min, max}
+	default:
+		c, err := strconv.Atoi(code)
+		if err != nil {
+			return r, invalid
+		}
+		r = statusRange{c, c}
+	}
+	if r.min < 100 || r.max > 599 {
+		return r, invalid
+	}
+	return r, nil
+}
+
+// Apakah aturan memerlukan isi body respons (memerlukan permintaan GET, bukan HEAD)
+func (r *responseRules) needBody() bool {
+	return r.body != "" || r.bodyRegexp != nil
+}
+
+func (r *responseRules) checkStatus(code int) bool {
+	for _, c := range r.codes {
+		if code >= c.min && code <= c.max {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *responseRules) checkHeaders(header http.Header) bool {
+	for _, h := range r.headers {
+		values, ok := header[h.name]
+		if !ok {
+			return false
+		}
+		if h.value == "" {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(strings.Join(values, ",")), h.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *responseRules) checkBody(body []byte) bool {
+	if r.body != "" && !strings.Contains(string(body), r.body) {
+		return false
+	}
+	if r.bodyRegexp != nil && !r.bodyRegexp.Match(body) {
+		return false
+	}
+	return true
+}
+
+// pingSamples colo
+func (p *Ping) httping(ip *net.IPAddr) ([]time.Duration, string) {
+	var colo string
 	hc := http.Client{
 		Timeout: time.Second * 2,
 		Transport: &http.Transport{
@@ -36,56 +180,52 @@ func (p *Ping) httping(ip *net.IPAddr) (int, time.Duration) {
 
 	// Kunjungi sekali dulu untuk mendapatkan kode status HTTP dan Cloudflare Colo
 	{
-		requ, err := http.NewRequest(http.MethodHead, URL, nil)
+		method := http.MethodHead
+		if httpingRules.needBody() { // Aturan body memerlukan isi respons, maka gunakan GET
+			method = http.MethodGet
+		}
+		requ, err := http.NewRequest(method, URL, nil)
 		if err != nil {
-			return 0, 0
+			return nil, ""
 		}
 		requ.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, seperti Gecko) Chrome/98.0.4758.80 Safari/537.36")
 		resp, err := hc.Do(requ)
 		if err != nil {
-			return 0, 0
+			return nil, ""
 		}
 		defer resp.Body.Close()
 
 		//fmt.Println("IP:", ip, "StatusCode:", resp.StatusCode, resp.Request.URL)
-		// Jika kode status HTTP tidak ditentukan, atau kode status yang ditentukan tidak valid, maka default hanya 200, 301, 302 yang dianggap berhasil
-		if HttpingStatusCode == 0 || HttpingStatusCode < 100 && HttpingStatusCode > 599 {
-			if resp.StatusCode != 200 && resp.StatusCode != 301 && resp.StatusCode != 302 {
-				return 0, 0
+		// Periksa kode status dan header respons sesuai aturan validasi
+		if !httpingRules.checkStatus(resp.StatusCode) || !httpingRules.checkHeaders(resp.Header) {
+			return nil, ""
+		}
+
+		if httpingRules.needBody() {
+			body, err := io.ReadAll(io.LimitReader(resp.Body, maxHttpingBodySize))
+			if err != nil || !httpingRules.checkBody(body) {
+				return nil, ""
 			}
 		} else {
-			if resp.StatusCode != HttpingStatusCode {
-				return 0, 0
-			}
+			io.Copy(io.Discard, resp.Body)
 		}
 
-		io.Copy(io.Discard, resp.Body)
-
-		// Hanya jika daerah tertentu ditentukan maka cocokkan dengan kode tiga huruf bandara
-		if HttpingCFColo != "" {
-			// Menentukan Cloudflare atau AWS CloudFront berdasarkan header Server dan menetapkan cfRay ke kode tiga huruf bandara masing-masing
-			cfRay := func() string {
-				if resp.Header.Get("Server") == "cloudflare" {
-					return resp.Header.Get("CF-RAY") // Contoh cf-ray: 7bd32409eda7b020-SJC
-				}
-				return resp.Header.Get("x-amz-cf-pop") // Contoh X-Amz-Cf-Pop: SIN52-P1
-			}()
-			colo := p.getColo(cfRay)
-			if colo == "" { // Jika tidak cocok dengan kode tiga huruf atau tidak sesuai dengan daerah tertentu, akhiri pengujian IP ini
-				return 0, 0
-			}
+		// Dapatkan kode lokasi node dari header respons sesuai penyedia CDN (-provider)
+		colo = p.getColo(resp.Header)
+		// Hanya jika daerah tertentu ditentukan maka IP yang tidak cocok dengan kode tiga huruf bandara dibuang
+		if coloFilterEnabled() && colo == "" { // Jika tidak cocok dengan kode tiga huruf atau tidak sesuai dengan daerah tertentu, akhiri pengujian IP ini
+			return nil, ""
 		}
 
 	}
 
 	// Ulangi pengujian untuk menghitung latensi
-	success := 0
-	var delay time.Duration
+	var samples []time.Duration
 	for i := 0; i < PingTimes; i++ {
 		requ, err := http.NewRequest(http.MethodHead, URL, nil)
 		if err != nil {
 			log.Fatal("Kesalahan yang tidak terduga, harap laporkan: ", err)
-			return 0, 0
+			return nil, ""
 		}
 		requ.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, seperti Gecko) Chrome/98.0.4758.80 Safari/537.36")
 		if i == PingTimes-1 {
@@ -96,15 +236,13 @@ func (p *Ping) httping(ip *net.IPAddr) (int, time.Duration) {
 		if err != nil {
 			continue
 		}
-		success++
 		io.Copy(io.Discard, resp.Body)
 		_ = resp.Body.Close()
-		duration := time.Since(startTime)
-		delay += duration
+		samples = append(samples, time.Since(startTime))
 
 	}
 
-	return success, delay
+	return samples, colo
 
 }
 
@@ -121,21 +259,27 @@ func MapColoMap() *sync.Map {
 	return colomap
 }
 
-func (p *Ping) getColo(b string) string {
-	if b == "" {
+func (p *Ping) getColo(header http.Header) string {
+	out := provider.Colo(header)
+	if out == "" {
 		return ""
 	}
-	// Cocokkan dengan kode tiga huruf bandara menggunakan regex dan kembalikan hasilnya
-	out := OutRegexp.FindString(b)
 
-	if HttpingCFColomap == nil {
-		return out
-	}
-	// Cocokkan kode tiga huruf bandara dengan daerah yang ditentukan
-	_, ok := HttpingCFColomap.Load(out)
-	if ok {
+	if coloAllowed(out) {
 		return out
 	}
 
 	return ""
+}
+
+// Cocokkan kode tiga huruf bandara dengan daerah yang ditentukan dan daerah yang dikecualikan
+func coloAllowed(colo string) bool {
+	if coloExcludeMap[colo] {
+		return false
+	}
+	if HttpingCFColomap == nil {
+		return true
+	}
+	_, ok := HttpingCFColomap.Load(colo)
+	return ok
 }
\ No newline at end of file
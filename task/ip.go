@@ -2,6 +2,7 @@ package task
 
 import (
 	"bufio"
+	"fmt"
 	"log"
 	"math/rand"
 	"net"
@@ -19,6 +20,8 @@ var (
 	// IPFile is the filename of IP Rangs
 	IPFile = defaultInputFile
 	IPText string
+	// FetchIP mengunduh rentang IP resmi penyedia ke IPFile sebelum pengujian
+	FetchIP = false
 )
 
 func InitRandSeed() {
@@ -148,6 +151,9 @@ func (r *IPRanges) chooseIPv6() {
 }
 
 func loadIPRanges() []*net.IPAddr {
+	if len(previous) > 0 { // Menguji ulang IP dari file hasil sebelumnya (-from-result)
+		return previousIPs()
+	}
 	ranges := newIPRanges()
 	if IPText != "" { // Dapatkan data rentang IP dari parameter
 		IPs := strings.Split(IPText, ",") // Pisahkan dengan koma menjadi array dan iterasi
@@ -167,6 +173,13 @@ func loadIPRanges() []*net.IPAddr {
 		if IPFile == "" {
 			IPFile = defaultInputFile
 		}
+		if FetchIP { // Unduh rentang IP resmi penyedia terlebih dahulu
+			n, err := provider.FetchIPRanges(IPFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Berhasil mengunduh %d rentang IP resmi %s ke file %s\n", n, provider.Name, IPFile)
+		}
 		file, err := os.Open(IPFile)
 		if err != nil {
 			log.Fatal(err)
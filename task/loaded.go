@@ -0,0 +1,114 @@
+package task
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+const (
+	loadedProbeInterval = 200 * time.Millisecond // Interval probe latensi selama pengujian unduh
+	idleProbeCount      = 3                      // Jumlah probe latensi tanpa beban sebelum pengujian unduh
+)
+
+// MaxLoadedDelta adalah batas atas kenaikan latensi saat beban dibandingkan latensi tanpa beban, 0 berarti tidak difilter (-tlb)
+var MaxLoadedDelta time.Duration
+
+// Probe latensi saat beban (bufferbloat): selama pengujian unduh menjenuhkan tautan melalui IP,
+// koneksi TCP ringan dibuat secara berkala ke IP yang sama dan waktu handshake-nya dicatat
+type latencyProbe struct {
+	m       sync.Mutex
+	samples []time.Duration
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func startLatencyProbe(ip *net.IPAddr) *latencyProbe {
+	p := &latencyProbe{stop: make(chan struct{}), done: make(chan struct{})}
+	address := net.JoinHostPort(ip.String(), fmt.Sprint(TCPPort))
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(loadedProbeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				delay, ok := probeLatency(address)
+				if !ok { // Probe yang gagal diabaikan, kehilangan paket sudah diukur saat pengujian latensi
+					continue
+				}
+				p.m.Lock()
+				p.samples = append(p.samples, delay)
+				p.m.Unlock()
+			}
+		}
+	}()
+	return p
+}
+
+// Menghentikan probe dan mengembalikan median latensi saat beban, 0 jika tidak ada probe yang berhasil
+func (p *latencyProbe) finish() time.Duration {
+	close(p.stop)
+	<-p.done
+	return medianLatency(p.samples)
+}
+
+// Satu probe latensi: waktu handshake TCP ke alamat, ok false jika koneksi gagal
+func probeLatency(address string) (time.Duration, bool) {
+	startTime := time.Now()
+	conn, err := net.DialTimeout("tcp", address, tcpConnectTimeout)
+	if err != nil {
+		return 0, false
+	}
+	conn.Close()
+	return time.Since(startTime), true
+}
+
+// Median latensi tanpa beban ke IP, diukur tepat sebelum pengujian unduh dengan probe yang sama seperti latencyProbe,
+// sehingga kenaikan latensi saat beban tidak bergantung pada metode pengujian latensi (TCPing atau HTTPing), 0 jika semua probe gagal
+func idleLatency(ip *net.IPAddr) time.Duration {
+	address := net.JoinHostPort(ip.String(), fmt.Sprint(TCPPort))
+	samples := make([]time.Duration, 0, idleProbeCount)
+	for i := 0; i < idleProbeCount; i++ {
+		if delay, ok := probeLatency(address); ok {
+			samples = append(samples, delay)
+		}
+	}
+	return medianLatency(samples)
+}
+
+// Median sampel latensi, 0 jika tidak ada sampel
+func medianLatency(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[len(samples)/2]
+}
+
+// Apakah kenaikan latensi saat beban masih dalam batas [-tlb]
+func loadedDeltaAllowed(data utils.CloudflareIPData) bool {
+	return MaxLoadedDelta <= 0 || data.LoadedDelay <= 0 || data.LoadedDelta() <= MaxLoadedDelta
+}
+
+// Menampilkan jumlah IP yang tidak memenuhi syarat karena latensi naik terlalu tinggi saat beban
+func printLoadedSummary(ipSet utils.PingDelaySet) {
+	if MaxLoadedDelta <= 0 {
+		return
+	}
+	rejected := 0
+	for _, v := range ipSet {
+		if !loadedDeltaAllowed(v) {
+			rejected++
+		}
+	}
+	if rejected > 0 {
+		fmt.Printf("\n[Info] %d IP tidak memenuhi syarat karena latensi saat beban naik lebih dari %d ms [-tlb].\n", rejected, MaxLoadedDelta.Milliseconds())
+	}
+}
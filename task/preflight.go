@@ -0,0 +1,148 @@
+package task
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+const (
+	preflightTimeout  = 10 * time.Second
+	preflightReadTime = 2 * time.Second  // Waktu maksimum membaca body untuk memperkirakan kecepatan
+	preflightMaxBytes = 20 * 1024 * 1024 // Data maksimum yang dibaca saat pemeriksaan awal
+)
+
+// SkipPreflight melewati pemeriksaan awal alamat pengujian (-skip-preflight)
+var SkipPreflight = false
+
+// Memeriksa alamat pengujian melalui resolusi DNS biasa sebelum pengujian latensi dimulai,
+// mengembalikan false jika tidak ada alamat yang dapat digunakan (pengujian sebaiknya dibatalkan)
+func Preflight() bool {
+	checkDownloadDefault()
+	if SkipPreflight || (Disable && !Httping) { // Alamat tidak digunakan jika pengujian unduh dinonaktifkan dalam mode TCPing
+		return true
+	}
+	fmt.Println("Memeriksa alamat pengujian...")
+	usable := 0
+	for _, u := range URLs {
+		if preflightURL(u) {
+			usable++
+		}
+	}
+	if usable == 0 {
+		fmt.Println("[Kesalahan] Tidak ada alamat pengujian [-url] yang dapat digunakan, periksa alamat di atas atau gunakan [-skip-preflight] untuk melewati pemeriksaan ini.")
+		return false
+	}
+	fmt.Println()
+	return true
+}
+
+// Memeriksa satu alamat, mengembalikan false jika alamat pasti tidak dapat digunakan
+func preflightURL(rawURL string) bool {
+	fail := func(format string, a ...interface{}) bool {
+		fmt.Printf("[Kesalahan] %s: %s\n", rawURL, fmt.Sprintf(format, a...))
+		return false
+	}
+	warn := func(format string, a ...interface{}) {
+		fmt.Printf("[Peringatan] %s: %s\n", rawURL, fmt.Sprintf(format, a...))
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fail("alamat tidak valid, harus diawali http:// atau https://, contoh: https://speed.cloudflare.com/__down?bytes=200000000")
+	}
+
+	var chain []string
+	client := &http.Client{
+		Timeout: preflightTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > 10 { // Batasi maksimal 10 kali pengalihan, sama dengan pengujian unduh
+				return http.ErrUseLastResponse
+			}
+			chain = append(chain, req.URL.String())
+			return nil
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fail("%v", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
+	resp, err := client.Do(req)
+	if err != nil {
+		// Resolusi DNS biasa dapat gagal (misalnya DNS diblokir) sementara pengujian melalui IP tetap berhasil, jadi hanya peringatan
+		warn("tidak dapat diakses melalui resolusi DNS biasa (%v), pemeriksaan dilewati", err)
+		return true
+	}
+	defer resp.Body.Close()
+
+	if len(chain) > 0 {
+		fmt.Printf("[Info] %s dialihkan: %s\n", rawURL, strings.Join(chain, " -> "))
+		if final := resp.Request.URL; final.Hostname() != u.Hostname() {
+			warn("dialihkan ke domain lain [%s], saat pengujian melalui IP yang ditentukan domain tersebut juga harus berada di %s, gunakan alamat akhir secara langsung", final.Hostname(), provider.Name)
+		}
+	}
+	if resp.StatusCode != 200 {
+		if Httping && httpingRules != nil && httpingRules.checkStatus(resp.StatusCode) && Disable {
+			return true // Hanya HTTPing, kode status ini diterima oleh aturan [-httping-code]
+		}
+		return fail("kode status %d, pengujian unduh memerlukan 200, periksa kembali alamat file (404 = file tidak ada, 403 = akses ditolak)", resp.StatusCode)
+	}
+	if provider.identity != nil && !provider.identity(resp.Header) {
+		warn("respons tidak memiliki header identitas %s (Server: %s), alamat ini kemungkinan tidak berada di belakang %s sehingga pengujian melalui IP %s akan gagal", provider.Name, resp.Header.Get("Server"), provider.Name, provider.Name)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		warn("respons berupa halaman web (text/html) dan bukan file uji, kemungkinan halaman login/captive portal")
+	}
+	if Disable {
+		return true
+	}
+	tooSmall := func(size int64) bool {
+		return fail("ukuran file hanya %s, terlalu kecil untuk pengujian unduh, gunakan file yang lebih besar", utils.FormatBytes(size))
+	}
+	// Dengan [-data-budget] body tidak dibaca (kecepatan tidak diperkirakan) agar kuota data tetap utuh untuk pengujian IP
+	if DataBudget > 0 {
+		if size := resp.ContentLength; size >= 0 && size < minDownloadSize {
+			return tooSmall(size)
+		}
+		return true
+	}
+
+	// Baca sebagian body untuk memperkirakan kecepatan, lalu bandingkan ukuran file dengan kecepatan x [-dt]
+	start := time.Now()
+	buffer := make([]byte, 32*1024)
+	var read int64
+	for time.Since(start) < preflightReadTime && read < preflightMaxBytes {
+		n, err := resp.Body.Read(buffer)
+		read += int64(n)
+		if err != nil {
+			break
+		}
+	}
+	consumeData(read)
+	elapsed := time.Since(start)
+	size := resp.ContentLength
+	if size < 0 && read < preflightMaxBytes && elapsed < preflightReadTime { // Ukuran tidak diketahui tetapi body sudah selesai dibaca
+		size = read
+	}
+	if size >= 0 && size < minDownloadSize {
+		return tooSmall(size)
+	}
+	if size < 0 {
+		fmt.Printf("[Info] %s: ukuran file tidak diketahui (tanpa Content-Length)\n", rawURL)
+		return true
+	}
+	speed := float64(read) / elapsed.Seconds()
+	if minSpeed := MinSpeed * 1024 * 1024; speed < minSpeed {
+		speed = minSpeed
+	}
+	if need := int64(speed * Timeout.Seconds()); size < need {
+		warn("ukuran file %s akan selesai diunduh dalam sekitar %.1f detik (kecepatan perkiraan %.2f MB/s), lebih singkat dari [-dt %d], hasil pengujian unduh menjadi kurang akurat, gunakan file minimal %s",
+			utils.FormatBytes(size), float64(size)/speed, speed/1024/1024, int(Timeout.Seconds()), utils.FormatBytes(need))
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultProvider = "cloudflare"
+
+// ProviderName adalah nama penyedia CDN yang diuji (-provider)
+var ProviderName = defaultProvider
+
+// Provider berisi informasi khusus setiap penyedia CDN
+type Provider struct {
+	Name string
+	// Mendapatkan kode lokasi (colo/POP) dari header respons, nil jika penyedia tidak menyediakannya
+	colo func(header http.Header) string
+	// Memeriksa apakah respons benar-benar berasal dari penyedia (header identitas), nil jika penyedia tidak memilikinya
+	identity func(header http.Header) bool
+	// Kode lokasi adalah kode milik penyedia (bukan kode tiga huruf bandara IATA), sehingga filter lokasi dan data lokasi tidak berlaku
+	ownCodes bool
+	// Endpoint trace yang mengembalikan colo=XXX, kosong jika penyedia tidak memilikinya (colo dibaca dari header respons [-url])
+	TraceURL string
+	// Port default penyedia (dengan TLS dan tanpa TLS)
+	TLSPorts   []int
+	PlainPorts []int
+	// File rentang IP resmi dan fungsi untuk mem-parse isinya menjadi daftar CIDR
+	IPRangeURLs []string
+	parseRanges func(body []byte) ([]string, error)
+}
+
+var providers = map[string]*Provider{
+	"cloudflare": {
+		Name: "cloudflare",
+		colo: func(header http.Header) string {
+			if header.Get("Server") == "cloudflare" {
+				return OutRegexp.FindString(header.Get("CF-RAY")) // Contoh cf-ray: 7bd32409eda7b020-SJC
+			}
+			return cloudfrontColo(header) // Kompatibel dengan perilaku lama: IP di luar Cloudflare dianggap AWS CloudFront
+		},
+		identity: func(header http.Header) bool {
+			return header.Get("Server") == "cloudflare" && header.Get("CF-RAY") != ""
+		},
+		TraceURL:    "https://speed.cloudflare.com/cdn-cgi/trace",
+		TLSPorts:    []int{443, 8443, 2053, 2083, 2087, 2096},
+		PlainPorts:  []int{80, 8080, 8880, 2052, 2082, 2086, 2095},
+		IPRangeURLs: []string{"https://www.cloudflare.com/ips-v4", "https://www.cloudflare.com/ips-v6"},
+		parseRanges: parseTextRanges,
+	},
+	"cloudfront": {
+		Name:        "cloudfront",
+		colo:        cloudfrontColo,
+		identity:    func(header http.Header) bool { return header.Get("X-Amz-Cf-Id") != "" },
+		TLSPorts:    []int{443},
+		PlainPorts:  []int{80},
+		IPRangeURLs: []string{"https://ip-ranges.amazonaws.com/ip-ranges.json"},
+		parseRanges: parseAWSRanges,
+	},
+	"fastly": {
+		Name: "fastly",
+		colo: func(header http.Header) string {
+			// Contoh X-Served-By: cache-iad-kiad7000025-IAD, cache-sin21720-SIN (entri terakhir adalah node terdekat dengan klien)
+			servedBy := header.Get("X-Served-By")
+			if i := strings.LastIndexByte(servedBy, ','); i >= 0 {
+				servedBy = servedBy[i+1:]
+			}
+			if i := strings.LastIndexByte(servedBy, '-'); i >= 0 {
+				return OutRegexp.FindString(servedBy[i+1:])
+			}
+			return ""
+		},
+		identity:    func(header http.Header) bool { return strings.HasPrefix(header.Get("X-Served-By"), "cache-") },
+		TLSPorts:    []int{443},
+		PlainPorts:  []int{80},
+		IPRangeURLs: []string{"https://api.fastly.com/public-ip-list"},
+		parseRanges: parseJSONRanges("addresses", "ipv6_addresses"),
+	},
+	"gcore": {
+		Name: "gcore",
+		colo: func(header http.Header) string {
+			// Contoh X-ID: sg1-up-gc38, awalan adalah kode lokasi Gcore (bukan kode tiga huruf bandara)
+			id := header.Get("X-ID")
+			if i := strings.IndexByte(id, '-'); i > 0 {
+				return strings.ToUpper(id[:i])
+			}
+			return ""
+		},
+		identity:    func(header http.Header) bool { return header.Get("X-ID") != "" },
+		ownCodes:    true,
+		TLSPorts:    []int{443},
+		PlainPorts:  []int{80},
+		IPRangeURLs: []string{"https://api.gcore.com/cdn/public-ip-list"},
+		parseRanges: parseJSONRanges("addresses", "addresses_v6"),
+	},
+	"akamai": {
+		Name: "akamai",
+		// Akamai tidak mengirimkan lokasi node dalam header respons standar dan tidak menerbitkan daftar rentang IP resmi
+		TLSPorts:   []int{443},
+		PlainPorts: []int{80},
+	},
+}
+
+// Contoh X-Amz-Cf-Pop: SIN52-P1
+func cloudfrontColo(header http.Header) string {
+	return OutRegexp.FindString(header.Get("X-Amz-Cf-Pop"))
+}
+
+var provider = providers[defaultProvider]
+
+// Memilih penyedia CDN berdasarkan -provider
+func SetProvider(name string) error {
+	if name == "" {
+		name = defaultProvider
+	}
+	p, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("penyedia [%s] tidak didukung, penyedia yang tersedia: %s", name, strings.Join(ProviderNames(), ", "))
+	}
+	if coloFilterEnabled() && !p.hasColo() {
+		return fmt.Errorf("penyedia [%s] tidak menyediakan informasi lokasi node, filter lokasi tidak dapat digunakan", p.Name)
+	}
+	if coloFilterEnabled() && p.ownCodes {
+		return fmt.Errorf("penyedia [%s] menggunakan kode lokasi sendiri (contoh: SG1) dan bukan kode bandara IATA, filter lokasi [-cfcolo] [-region] [-country] [-cfcolo-exclude] tidak dapat digunakan", p.Name)
+	}
+	provider, ProviderName = p, p.Name
+	return nil
+}
+
+// Daftar nama penyedia yang didukung
+func ProviderNames() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Penyedia CDN yang sedang digunakan
+func CurrentProvider() *Provider {
+	return provider
+}
+
+// Apakah penyedia dapat memberikan informasi lokasi node (melalui trace atau header respons)
+func (p *Provider) hasColo() bool {
+	return p.TraceURL != "" || p.colo != nil
+}
+
+// Mendapatkan kode lokasi node dari header respons
+func (p *Provider) Colo(header http.Header) string {
+	if p.colo == nil {
+		return ""
+	}
+	return p.colo(header)
+}
+
+// Apakah port adalah port tanpa TLS milik penyedia
+func (p *Provider) isPlainPort(port int) bool {
+	for _, v := range p.PlainPorts {
+		if v == port {
+			return true
+		}
+	}
+	return false
+}
+
+// Apakah port termasuk port default penyedia
+func (p *Provider) IsDefaultPort(port int) bool {
+	for _, v := range p.TLSPorts {
+		if v == port {
+			return true
+		}
+	}
+	return p.isPlainPort(port)
+}
+
+// Mengunduh file rentang IP resmi penyedia dan menulisnya ke file (satu CIDR per baris)
+func (p *Provider) FetchIPRanges(file string) (int, error) {
+	if len(p.IPRangeURLs) == 0 {
+		return 0, fmt.Errorf("penyedia [%s] tidak menerbitkan daftar rentang IP resmi", p.Name)
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	var ranges []string
+	for _, url := range p.IPRangeURLs {
+		resp, err := client.Get(url)
+		if err != nil {
+			return 0, fmt.Errorf("tidak dapat mengambil rentang IP dari %s: %v", url, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return 0, fmt.Errorf("tidak dapat membaca rentang IP dari %s: %v", url, err)
+		}
+		if resp.StatusCode != 200 {
+			return 0, fmt.Errorf("tidak dapat mengambil rentang IP dari %s: kode status %d", url, resp.StatusCode)
+		}
+		r, err := p.parseRanges(body)
+		if err != nil {
+			return 0, fmt.Errorf("rentang IP dari %s tidak valid: %v", url, err)
+		}
+		ranges = append(ranges, r...)
+	}
+	if len(ranges) == 0 {
+		return 0, fmt.Errorf("rentang IP resmi penyedia [%s] kosong", p.Name)
+	}
+	if err := os.WriteFile(file, []byte(strings.Join(ranges, "\n")+"\n"), 0644); err != nil {
+		return 0, fmt.Errorf("tidak dapat menulis rentang IP ke file [%s]: %v", file, err)
+	}
+	return len(ranges), nil
+}
+
+// Format teks, satu CIDR per baris (Cloudflare)
+func parseTextRanges(body []byte) (ranges []string, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(line); err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, line)
+	}
+	return ranges, scanner.Err()
+}
+
+// Format ip-ranges.json milik AWS, hanya ambil layanan CLOUDFRONT
+func parseAWSRanges(body []byte) (ranges []string, err error) {
+	var data struct {
+		Prefixes []struct {
+			IPPrefix string `json:"ip_prefix"`
+			Service  string `json:"service"`
+		} `json:"prefixes"`
+		IPv6Prefixes []struct {
+			IPv6Prefix string `json:"ipv6_prefix"`
+			Service    string `json:"service"`
+		} `json:"ipv6_prefixes"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	for _, v := range data.Prefixes {
+		if v.Service == "CLOUDFRONT" {
+			ranges = append(ranges, v.IPPrefix)
+		}
+	}
+	for _, v := range data.IPv6Prefixes {
+		if v.Service == "CLOUDFRONT" {
+			ranges = append(ranges, v.IPv6Prefix)
+		}
+	}
+	return ranges, nil
+}
+
+// Format JSON berisi array CIDR pada kunci yang ditentukan (Fastly, Gcore)
+func parseJSONRanges(keys ...string) func(body []byte) ([]string, error) {
+	return func(body []byte) (ranges []string, err error) {
+		var data map[string][]string
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			ranges = append(ranges, data[key]...)
+		}
+		return ranges, nil
+	}
+}
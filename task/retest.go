@@ -0,0 +1,86 @@
+package task
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+var (
+	// FromResult adalah file hasil sebelumnya (CSV/JSON/NDJSON) yang IP-nya diuji ulang alih-alih memindai rentang IP (-from-result)
+	FromResult string
+	// Top adalah jumlah IP teratas dari file hasil sebelumnya yang diuji ulang, 0 berarti semua (-top)
+	Top int
+
+	previous []utils.CloudflareIPData // Hasil sebelumnya yang diuji ulang
+)
+
+// Membaca file hasil sebelumnya untuk pengujian ulang, dipanggil saat program dimulai.
+// Port diambil dari file hasil kecuali [-tp] ditentukan (portSet), jumlah pengujian unduh
+// mencakup semua IP kecuali [-dn] ditentukan (countSet)
+func LoadPrevious(portSet, countSet bool) error {
+	if FromResult == "" {
+		return nil
+	}
+	data, run, err := utils.ReadResults(FromResult)
+	if err != nil {
+		return err
+	}
+	if Top > 0 && len(data) > Top { // File hasil sudah berurutan dari yang terbaik
+		data = data[:Top]
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("file hasil [%s] tidak berisi IP", FromResult)
+	}
+
+	// Semua IP diuji melalui satu port: [-tp] jika ditentukan (port dalam file hasil diabaikan),
+	// jika tidak port dari file hasil, yang harus sama untuk semua IP
+	if !portSet {
+		port := 0
+		for _, v := range data {
+			if v.Port == 0 { // File hasil format lama tanpa kolom port
+				continue
+			}
+			if port > 0 && v.Port != port {
+				return fmt.Errorf("file hasil [%s] berisi IP dengan port berbeda (%d dan %d), semua IP diuji ulang melalui satu port, tentukan port dengan [-tp]", FromResult, port, v.Port)
+			}
+			port = v.Port
+		}
+		if port == 0 {
+			port = run.Port
+		}
+		if port > 0 {
+			TCPPort = port
+		}
+	}
+	previous = data
+	if !countSet {
+		TestCount = len(previous)
+	}
+	fmt.Printf("Menguji ulang %d IP dari file hasil sebelumnya %s (port %d)\n", len(previous), FromResult, TCPPort)
+	return nil
+}
+
+// IP dari file hasil sebelumnya
+func previousIPs() []*net.IPAddr {
+	ips := make([]*net.IPAddr, 0, len(previous))
+	for _, v := range previous {
+		ips = append(ips, v.IP)
+	}
+	return ips
+}
+
+// AttachPrevious menautkan hasil sebelumnya ke hasil baru dengan IP yang sama agar selisihnya dapat ditulis ke file hasil
+func AttachPrevious(data utils.DownloadSpeedSet) {
+	if len(previous) == 0 {
+		return
+	}
+	byIP := make(map[string]*utils.CloudflareIPData, len(previous))
+	for i := range previous {
+		byIP[previous[i].IP.String()] = &previous[i]
+	}
+	for i := range data {
+		data[i].Previous = byIP[data[i].IP.String()]
+	}
+}
@@ -0,0 +1,49 @@
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPrevious(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		portSet bool
+		tcpPort int
+		want    int // Jumlah IP yang diuji ulang, -1 berarti error
+		port    int
+	}{
+		{"port from file", "Alamat IP,Port\n1.1.1.1,443\n1.0.0.1,443\n", false, 8443, 2, 443},
+		{"-tp overrides file port", "Alamat IP,Port\n1.1.1.1,443\n1.0.0.1,443\n", true, 8443, 2, 8443},
+		{"old file without ports", "Alamat IP,Terkirim\n1.1.1.1,4\n", false, 2053, 1, 2053},
+		{"mixed ports need -tp", "Alamat IP,Port\n1.1.1.1,443\n1.0.0.1,8443\n", false, 443, -1, 0},
+		{"mixed ports with -tp", "Alamat IP,Port\n1.1.1.1,443\n1.0.0.1,8443\n", true, 2096, 2, 2096},
+	}
+	oldPort, oldCount, oldFrom := TCPPort, TestCount, FromResult
+	defer func() { TCPPort, TestCount, FromResult, previous = oldPort, oldCount, oldFrom, nil }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			FromResult = filepath.Join(t.TempDir(), "result.csv")
+			if err := os.WriteFile(FromResult, []byte(tt.csv), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			TCPPort, previous = tt.tcpPort, nil
+			err := LoadPrevious(tt.portSet, false)
+			if tt.want < 0 {
+				if err == nil || !strings.Contains(err.Error(), "[-tp]") {
+					t.Errorf("LoadPrevious error = %v, want error asking for [-tp]", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(previous) != tt.want || TestCount != tt.want || TCPPort != tt.port {
+				t.Errorf("previous = %d, TestCount = %d, TCPPort = %d, want %d, %d, %d", len(previous), TestCount, TCPPort, tt.want, tt.want, tt.port)
+			}
+		})
+	}
+}
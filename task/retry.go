@@ -0,0 +1,128 @@
+package task
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+// Alasan kegagalan pengujian unduh, ditulis ke file hasil
+const (
+	failDial    = "dial"    // Koneksi TCP gagal dibuat
+	failTLS     = "tls"     // Handshake TLS gagal (sertifikat, SNI ditolak, koneksi diputus saat handshake)
+	failTimeout = "timeout" // Tidak ada respons dalam [-dt]
+	failReset   = "reset"   // Koneksi terputus di tengah body
+	failSize    = "size"    // File terlalu kecil untuk pengujian unduh
+	failStatus  = "status " // Kode status bukan 200, contoh: status 403
+	failOther   = "error"
+)
+
+const defaultRetryWait = 500 * time.Millisecond
+
+var (
+	// Retries adalah jumlah percobaan ulang pengujian unduh jika terjadi kegagalan sementara (-dr)
+	Retries = 1
+	// RetryWait adalah jeda sebelum percobaan ulang (-drw)
+	RetryWait = defaultRetryWait
+)
+
+// Mengklasifikasikan kesalahan koneksi/pembacaan menjadi alasan kegagalan
+func classifyError(err error) string {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return failDial
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return failTimeout
+	}
+	var (
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	if errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) || strings.Contains(err.Error(), "tls:") {
+		return failTLS
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return failReset
+	}
+	return failOther
+}
+
+// Alasan kegagalan untuk kode status selain 200
+func statusFailure(code int) string {
+	return failStatus + strconv.Itoa(code)
+}
+
+// Apakah kegagalan bersifat sementara sehingga layak dicoba ulang,
+// kegagalan karena alamat (404, file terlalu kecil, dll.) tidak dicoba ulang
+func isTransient(failure string) bool {
+	switch failure {
+	case failDial, failTLS, failTimeout, failReset:
+		return true
+	}
+	if strings.HasPrefix(failure, failStatus) {
+		code, _ := strconv.Atoi(strings.TrimPrefix(failure, failStatus))
+		return code == 429 || code >= 500
+	}
+	return false
+}
+
+// Apakah kegagalan disebabkan oleh alamat unduh (kode status selain 200, file terlalu kecil) sehingga alamat lain layak dicoba,
+// kegagalan koneksi (dial, tls, timeout, reset) disebabkan oleh IP dan tidak akan berhasil dengan alamat lain
+func isURLFailure(failure string) bool {
+	return failure == failSize || strings.HasPrefix(failure, failStatus)
+}
+
+// Menguji kecepatan unduh satu alamat dengan percobaan ulang untuk kegagalan sementara,
+// jika percobaan ulang gagal total maka hasil parsial sebelumnya (misalnya terputus di tengah body) tetap digunakan
+func downloadWithRetry(ip *net.IPAddr, url string) (result downloadResult) {
+	for attempt := 0; ; attempt++ {
+		retry := downloadHandler(ip, url, Connections)
+		if attempt == 0 || retry.url != "" || result.url == "" {
+			result = retry
+		}
+		if result.failure == "" || !isTransient(result.failure) || attempt >= Retries || budgetExhausted() {
+			return
+		}
+		time.Sleep(RetryWait)
+	}
+}
+
+// Menampilkan jumlah IP berdasarkan alasan kegagalan pengujian unduh
+func printFailureSummary(ipSet utils.PingDelaySet) {
+	counts := make(map[string]int)
+	for _, v := range ipSet {
+		if v.DownloadError != "" {
+			counts[v.DownloadError]++
+		}
+	}
+	if len(counts) == 0 {
+		return
+	}
+	reasons := make([]string, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if counts[reasons[i]] != counts[reasons[j]] {
+			return counts[reasons[i]] > counts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%s %d", reason, counts[reason])
+	}
+	fmt.Printf("\n[Info] Kegagalan pengujian unduh (setelah %d kali percobaan ulang): %s\n", Retries, strings.Join(parts, ", "))
+}
@@ -0,0 +1,281 @@
+package task
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/server"
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+// Menjalankan server uji kecepatan lokal dan mengarahkan pengujian ke sana: semua koneksi ke IP pengujian
+// menggunakan port server (TCPPort), parameter global dikembalikan setelah pengujian selesai
+func startTestServer(t *testing.T) (*httptest.Server, *net.IPAddr) {
+	ts := httptest.NewServer(server.Handler("TST"))
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	oldPort, oldURL, oldURLs, oldTimeout, oldConns := TCPPort, URL, URLs, Timeout, Connections
+	oldTrace, oldUpload, oldUploadURL, oldCount, oldParallel := TraceURL, Upload, UploadURL, TestCount, Parallel
+	t.Cleanup(func() {
+		ts.Close()
+		TCPPort, URL, URLs, Timeout, Connections = oldPort, oldURL, oldURLs, oldTimeout, oldConns
+		TraceURL, Upload, UploadURL, TestCount, Parallel = oldTrace, oldUpload, oldUploadURL, oldCount, oldParallel
+		_ = SetProvider(defaultProvider)
+	})
+	if err := SetProvider("cloudflare"); err != nil {
+		t.Fatal(err)
+	}
+	TCPPort = port
+	Timeout = 2 * time.Second
+	return ts, &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+}
+
+func TestTraceColo(t *testing.T) {
+	ts, ip := startTestServer(t)
+	TraceURL = ts.URL + "/cdn-cgi/trace"
+	if colo := traceColo(ip); colo != "TST" {
+		t.Errorf("traceColo = %q, want TST", colo)
+	}
+}
+
+func TestProviderColo(t *testing.T) {
+	tests := []struct {
+		provider string
+		header   http.Header
+		want     string
+	}{
+		{"cloudflare", http.Header{"Server": {"cloudflare"}, "Cf-Ray": {"7bd32409eda7b020-SJC"}}, "SJC"},
+		{"cloudflare", http.Header{"Cf-Ray": {"7bd32409eda7b020-SJC"}}, ""},
+		{"cloudfront", http.Header{"X-Amz-Cf-Pop": {"NRT57-P2"}}, "NRT"},
+	}
+	for _, tt := range tests {
+		if got := providers[tt.provider].Colo(tt.header); got != tt.want {
+			t.Errorf("%s Colo(%v) = %q, want %q", tt.provider, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadHandler(t *testing.T) {
+	ts, ip := startTestServer(t)
+	const size = 4 * 1000 * 1000
+	tests := []struct {
+		name  string
+		conns int
+	}{
+		{"single", 1},
+		{"multi", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloadURL := ts.URL + "/__down?bytes=" + strconv.Itoa(size)
+			result := downloadHandler(ip, downloadURL, tt.conns)
+			if result.failure != "" || result.invalid != "" {
+				t.Fatalf("failure = %q, invalid = %q", result.failure, result.invalid)
+			}
+			if result.url != downloadURL {
+				t.Errorf("url = %q, want %q", result.url, downloadURL)
+			}
+			if want := int64(size * tt.conns); result.stats.Bytes != want {
+				t.Errorf("bytes = %d, want %d", result.stats.Bytes, want)
+			}
+			if result.stats.Average <= 0 || result.stats.Peak < result.stats.Average {
+				t.Errorf("average = %.0f, peak = %.0f", result.stats.Average, result.stats.Peak)
+			}
+			if result.idle <= 0 {
+				t.Errorf("idle = %v, want idle baseline > 0", result.idle)
+			}
+			if tt.conns > 1 && len(result.connSpeeds) != tt.conns {
+				t.Errorf("connSpeeds = %v, want %d connections", result.connSpeeds, tt.conns)
+			}
+		})
+	}
+}
+
+func TestDownloadFailure(t *testing.T) {
+	ts, ip := startTestServer(t)
+	if result := downloadHandler(ip, ts.URL+"/__down?bytes=-1", 1); result.failure != statusFailure(http.StatusBadRequest) {
+		t.Errorf("failure = %q, want %q", result.failure, statusFailure(http.StatusBadRequest))
+	}
+}
+
+func TestTestDownloadSpeedParallel(t *testing.T) {
+	ts, ip := startTestServer(t)
+	URLs = []string{ts.URL + "/__down?bytes=2000000"}
+	Connections, TestCount, Parallel = 1, 2, 2
+	SkipPreflight = true
+	defer func() { SkipPreflight = false }()
+
+	ipSet := make(utils.PingDelaySet, 3)
+	for i := range ipSet {
+		ipSet[i] = utils.CloudflareIPData{PingData: &utils.PingData{IP: ip, Sended: 1, Received: 1}}
+	}
+	speedSet := TestDownloadSpeed(ipSet)
+	if len(speedSet) != 2 {
+		t.Fatalf("len(speedSet) = %d, want %d (-dn)", len(speedSet), 2)
+	}
+	for _, v := range speedSet {
+		if v.DownloadSpeed <= 0 {
+			t.Errorf("DownloadSpeed = %.0f, want > 0", v.DownloadSpeed)
+		}
+	}
+}
+
+func TestUploadHandler(t *testing.T) {
+	ts, ip := startTestServer(t)
+	UploadURL = ts.URL + "/__up"
+	Timeout = 500 * time.Millisecond
+	if speed := uploadHandler(ip); speed <= 0 {
+		t.Errorf("uploadHandler = %.0f, want > 0", speed)
+	}
+}
+
+func TestTestUploadSpeedLimit(t *testing.T) {
+	ts, ip := startTestServer(t)
+	Upload, UploadURL = true, ts.URL+"/__up"
+	Timeout = 300 * time.Millisecond
+	TestCount, Parallel = 2, 2
+	oldMin := MinUploadSpeed
+	defer func() { MinUploadSpeed = oldMin }()
+
+	tests := []struct {
+		name     string
+		minSpeed float64
+		rows     int // Jumlah baris hasil
+		uploaded int // Jumlah baris dengan kecepatan unggah
+	}{
+		{"untested rows kept", 0, 5, 2},
+		{"-ul applies to tested rows", 0.001, 2, 2},
+		{"nothing reaches -ul", 1e9, 5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			MinUploadSpeed = tt.minSpeed
+			speedSet := make(utils.DownloadSpeedSet, 5) // Contoh hasil -dd: semua IP hasil pengujian latensi
+			for i := range speedSet {
+				speedSet[i] = utils.CloudflareIPData{PingData: &utils.PingData{IP: ip, Sended: 1, Received: 1}}
+			}
+			data := TestUploadSpeed(speedSet)
+			uploaded := 0
+			for _, v := range data {
+				if v.UploadSpeed > 0 {
+					uploaded++
+				}
+			}
+			if len(data) != tt.rows || uploaded != tt.uploaded {
+				t.Errorf("rows = %d, uploaded = %d, want %d, %d", len(data), uploaded, tt.rows, tt.uploaded)
+			}
+			for i := TestCount; i < len(speedSet); i++ {
+				if speedSet[i].UploadSpeed != 0 {
+					t.Errorf("speedSet[%d].UploadSpeed = %.0f, only the first %d IPs (-dn) should be tested", i, speedSet[i].UploadSpeed, TestCount)
+				}
+			}
+		})
+	}
+}
+
+func TestPreflightDataBudget(t *testing.T) {
+	ts, _ := startTestServer(t)
+	oldBudget, oldUsed := DataBudget, dataUsed
+	defer func() { DataBudget, dataUsed = oldBudget, oldUsed }()
+
+	tests := []struct {
+		name   string
+		budget int64
+		bytes  int
+		ok     bool
+		used   bool
+	}{
+		{"no budget", 0, 5000000, true, true},
+		{"budget", 10 * 1000 * 1000, 5000000, true, false},
+		{"budget, file too small", 10 * 1000 * 1000, 1000, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DataBudget, dataUsed = tt.budget, 0
+			if ok := preflightURL(ts.URL + "/__down?bytes=" + strconv.Itoa(tt.bytes)); ok != tt.ok {
+				t.Errorf("preflightURL = %v, want %v", ok, tt.ok)
+			}
+			if used := DataUsed() > 0; used != tt.used {
+				t.Errorf("DataUsed = %d, want data used = %v", DataUsed(), tt.used)
+			}
+		})
+	}
+}
+
+func TestDownloadWithFallback(t *testing.T) {
+	ts, ip := startTestServer(t)
+	good := ts.URL + "/__down?bytes=2000000"
+	tests := []struct {
+		name    string
+		urls    []string
+		url     string
+		failure string
+	}{
+		{"status falls back", []string{ts.URL + "/__down?bytes=-1", good}, good, ""},
+		{"too small falls back", []string{ts.URL + "/__down?bytes=1000", good}, good, ""},
+		{"all URLs fail", []string{ts.URL + "/__down?bytes=-1", ts.URL + "/__down?bytes=1000"}, "", failSize},
+	}
+	oldRetries := Retries
+	defer func() { Retries = oldRetries }()
+	Retries, Connections = 0, 1
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			URLs = tt.urls
+			result := downloadWithFallback(ip, 0)
+			if result.url != tt.url || result.failure != tt.failure {
+				t.Errorf("url = %q, failure = %q, want %q, %q", result.url, result.failure, tt.url, tt.failure)
+			}
+		})
+	}
+}
+
+func TestIsURLFailure(t *testing.T) {
+	tests := []struct {
+		failure string
+		want    bool
+	}{
+		{statusFailure(404), true},
+		{statusFailure(503), true},
+		{failSize, true},
+		{failDial, false},
+		{failTLS, false},
+		{failTimeout, false},
+		{failReset, false},
+	}
+	for _, tt := range tests {
+		if got := isURLFailure(tt.failure); got != tt.want {
+			t.Errorf("isURLFailure(%q) = %v, want %v", tt.failure, got, tt.want)
+		}
+	}
+}
+
+func TestSetProviderLocationFilter(t *testing.T) {
+	oldExclude := coloExcludeMap
+	defer func() { coloExcludeMap = oldExclude; _ = SetProvider(defaultProvider) }()
+	tests := []struct {
+		provider string
+		filter   bool
+		ok       bool
+	}{
+		{"cloudflare", true, true},
+		{"fastly", true, true},
+		{"gcore", false, true},
+		{"gcore", true, false},
+		{"akamai", true, false},
+	}
+	for _, tt := range tests {
+		coloExcludeMap = nil
+		if tt.filter {
+			coloExcludeMap = map[string]bool{"SIN": true}
+		}
+		if err := SetProvider(tt.provider); (err == nil) != tt.ok {
+			t.Errorf("SetProvider(%s) with location filter %v: error = %v, want ok %v", tt.provider, tt.filter, err, tt.ok)
+		}
+	}
+}
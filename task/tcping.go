@@ -3,8 +3,8 @@ package task
 import (
 	"fmt"
 	"net"
-	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -75,7 +75,7 @@ func (p *Ping) Run() utils.PingDelaySet {
 	}
 	p.wg.Wait()
 	p.bar.Done()
-	sort.Sort(p.csv)
+	p.csv.Sort()
 	return p.csv
 }
 
@@ -85,6 +85,14 @@ func (p *Ping) start(ip *net.IPAddr) {
 	<-p.control
 }
 
+// Apakah pengujian melalui TLS: jika alamat pengujian digunakan (HTTPing/unduh) berdasarkan skemanya, jika tidak berdasarkan port penyedia
+func useTLS() bool {
+	if Httping || !Disable {
+		return strings.HasPrefix(strings.ToLower(URL), "https://")
+	}
+	return !provider.isPlainPort(TCPPort)
+}
+
 // bool connectionSucceed float32 time
 func (p *Ping) tcping(ip *net.IPAddr) (bool, time.Duration) {
 	startTime := time.Now()
@@ -103,18 +111,35 @@ func (p *Ping) tcping(ip *net.IPAddr) (bool, time.Duration) {
 	return true, duration
 }
 
-// pingReceived pingTotalTime
-func (p *Ping) checkConnection(ip *net.IPAddr) (recv int, totalDelay time.Duration) {
+// pingSamples colo
+func (p *Ping) checkConnection(ip *net.IPAddr) (samples []time.Duration, colo string) {
 	if Httping {
-		recv, totalDelay = p.httping(ip)
-		return
+		return p.httping(ip)
 	}
 	for i := 0; i < PingTimes; i++ {
 		if ok, delay := p.tcping(ip); ok {
-			recv++
-			totalDelay += delay
+			samples = append(samples, delay)
+		}
+	}
+	return
+}
+
+// Latensi rata-rata dan jitter (rata-rata selisih latensi antar pengujian berhasil yang berturut-turut)
+func pingStats(samples []time.Duration) (delay, jitter time.Duration) {
+	for i, v := range samples {
+		delay += v
+		if i > 0 {
+			diff := v - samples[i-1]
+			if diff < 0 {
+				diff = -diff
+			}
+			jitter += diff
 		}
 	}
+	delay /= time.Duration(len(samples))
+	if len(samples) > 1 {
+		jitter /= time.Duration(len(samples) - 1)
+	}
 	return
 }
 
@@ -128,7 +153,8 @@ func (p *Ping) appendIPData(data *utils.PingData) {
 
 // handle tcping
 func (p *Ping) tcpingHandler(ip *net.IPAddr) {
-	recv, totalDlay := p.checkConnection(ip)
+	samples, colo := p.checkConnection(ip)
+	recv := len(samples)
 	nowAble := len(p.csv)
 	if recv != 0 {
 		nowAble++
@@ -139,9 +165,13 @@ func (p *Ping) tcpingHandler(ip *net.IPAddr) {
 	}
 	data := &utils.PingData{
 		IP:       ip,
+		Port:     TCPPort,
+		TLS:      useTLS(),
 		Sended:   PingTimes,
 		Received: recv,
-		Delay:    totalDlay / time.Duration(recv),
+		Colo:     colo,
 	}
+	data.Delay, data.Jitter = pingStats(samples)
+	fillLocation(data)
 	p.appendIPData(data)
 }
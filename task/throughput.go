@@ -0,0 +1,119 @@
+package task
+
+import (
+	"time"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+const (
+	maxWarmUp      = 2 * time.Second // Batas atas waktu pemanasan (TCP slow-start) yang tidak dihitung dalam kecepatan stabil
+	warmUpFraction = 0.25            // Waktu pemanasan paling banyak 25% dari durasi pengujian
+	peakFraction   = 0.1             // Jendela kecepatan puncak adalah 10% dari durasi pengujian
+	minSamples     = 4               // Jumlah sampel minimum untuk menghitung kecepatan stabil dan puncak
+	throttleTail   = 0.25            // Bagian akhir pengujian yang diperiksa untuk pembatasan kecepatan
+	throttleRatio  = 0.5             // Dianggap dibatasi jika kecepatan bagian akhir di bawah 50% kecepatan puncak
+)
+
+// Satu sampel: waktu sejak pengujian dimulai dan jumlah byte kumulatif yang telah diterima
+type throughputSample struct {
+	at    time.Duration
+	bytes int64
+}
+
+// Estimator kecepatan berdasarkan sampel byte yang diberi cap waktu
+//
+//   - Rata-rata: total byte / total waktu
+//   - Stabil: byte setelah waktu pemanasan / waktu setelah pemanasan, pemanasan = min(2 detik, 25% durasi),
+//     sehingga fase TCP slow-start di awal tidak menurunkan hasil
+//   - Puncak: kecepatan tertinggi dalam jendela geser sepanjang 10% durasi (minimal satu interval sampel)
+//   - Dibatasi: kecepatan pada 25% terakhir durasi di bawah 50% kecepatan puncak, misalnya pembatasan setelah N detik atau macet
+//
+// Jika pengujian terlalu singkat (sampel kurang dari 4), kecepatan stabil dan puncak sama dengan rata-rata.
+type throughputEstimator struct {
+	samples []throughputSample
+}
+
+func newThroughputEstimator() *throughputEstimator {
+	return &throughputEstimator{samples: []throughputSample{{0, 0}}}
+}
+
+// Tambahkan sampel jumlah byte kumulatif pada waktu at
+func (t *throughputEstimator) add(at time.Duration, bytes int64) {
+	if last := t.samples[len(t.samples)-1]; at <= last.at {
+		return
+	}
+	t.samples = append(t.samples, throughputSample{at, bytes})
+}
+
+// Hitung hasil akhir, semua kecepatan dalam byte/detik
+func (t *throughputEstimator) stats() (s utils.SpeedStats) {
+	last := t.samples[len(t.samples)-1]
+	s.Bytes, s.Elapsed = last.bytes, last.at
+	s.Series = t.series()
+	if last.at <= 0 {
+		return
+	}
+	s.Average = rate(t.samples[0], last)
+	s.Sustained, s.Peak = s.Average, s.Average
+	if len(t.samples) < minSamples {
+		return
+	}
+
+	// Kecepatan stabil, mulai dari sampel pertama setelah waktu pemanasan
+	warmUp := time.Duration(float64(last.at) * warmUpFraction)
+	if warmUp > maxWarmUp {
+		warmUp = maxWarmUp
+	}
+	for _, v := range t.samples {
+		if v.at >= warmUp {
+			if v.at < last.at {
+				s.Sustained = rate(v, last)
+			}
+			break
+		}
+	}
+
+	// Kecepatan puncak, jendela geser dengan panjang minimal window
+	window := time.Duration(float64(last.at) * peakFraction)
+	s.Peak = 0
+	j := 0
+	for i := 1; i < len(t.samples); i++ {
+		for j+1 < i && t.samples[i].at-t.samples[j+1].at >= window {
+			j++
+		}
+		if t.samples[i].at-t.samples[j].at >= window {
+			if r := rate(t.samples[j], t.samples[i]); r > s.Peak {
+				s.Peak = r
+			}
+		}
+	}
+	if s.Peak < s.Sustained { // Tidak ada jendela penuh (misalnya sampel tidak merata), gunakan kecepatan stabil
+		s.Peak = s.Sustained
+	}
+
+	// Pembatasan kecepatan, bandingkan kecepatan bagian akhir dengan kecepatan puncak
+	tail := time.Duration(float64(last.at) * (1 - throttleTail))
+	for _, v := range t.samples {
+		if v.at >= tail {
+			if v.at < last.at {
+				s.Throttled = rate(v, last) < s.Peak*throttleRatio
+			}
+			break
+		}
+	}
+	return
+}
+
+// Byte yang diterima di antara setiap dua sampel berurutan
+func (t *throughputEstimator) series() []utils.SeriesPoint {
+	series := make([]utils.SeriesPoint, 0, len(t.samples)-1)
+	for i := 1; i < len(t.samples); i++ {
+		series = append(series, utils.SeriesPoint{At: t.samples[i].at, Bytes: t.samples[i].bytes - t.samples[i-1].bytes})
+	}
+	return series
+}
+
+func rate(from, to throughputSample) float64 {
+	return float64(to.bytes-from.bytes) / (to.at - from.at).Seconds()
+}
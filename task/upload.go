@@ -0,0 +1,165 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+const (
+	defaultUploadURL           = "https://speed.cloudflare.com/__up"
+	defaultMinUpload   float64 = 0.0
+	maxUploadSize              = 1 << 30         // Batas data unggah untuk satu IP (1 GB)
+	uploadResponseWait         = 5 * time.Second // Waktu tunggu respons server setelah data selesai dikirim
+)
+
+var (
+	// Upload mengaktifkan tahap pengujian unggah setelah pengujian unduh (-up)
+	Upload         = false
+	UploadURL      = defaultUploadURL
+	MinUploadSpeed = defaultMinUpload
+
+	uploadChunk = func() []byte { // Data acak agar tidak dapat dikompresi di tengah jalan
+		b := make([]byte, 64*1024)
+		rand.Read(b)
+		return b
+	}()
+)
+
+func checkUploadDefault() {
+	if UploadURL == "" {
+		UploadURL = defaultUploadURL
+	}
+	if MinUploadSpeed <= 0.0 {
+		MinUploadSpeed = defaultMinUpload
+	}
+}
+
+// Menguji kecepatan unggah IP hasil pengujian unduh, lalu filter berdasarkan [batas bawah kecepatan unggah].
+// Hanya [-dn] IP teratas yang diuji (hasil dengan [-dd] atau tanpa IP yang mencapai [-sl] berisi semua IP hasil pengujian latensi),
+// [-dp] IP diuji bersamaan
+func TestUploadSpeed(speedSet utils.DownloadSpeedSet) (data utils.DownloadSpeedSet) {
+	checkUploadDefault()
+	if !Upload {
+		return speedSet
+	}
+	if len(speedSet) <= 0 {
+		fmt.Println("\n[Informasi] Jumlah IP hasil tes kecepatan unduh adalah 0, melewati tes kecepatan unggah.")
+		return
+	}
+	// Hanya [-dn] IP teratas yang diuji kecepatan unggahnya, IP lainnya tetap ada dalam hasil jika [-ul] tidak ditentukan
+	count := len(speedSet)
+	if count > TestCount {
+		count = TestCount
+	}
+	workers := Parallel
+	if workers > count {
+		workers = count
+	}
+	fmt.Printf("Mulai tes kecepatan unggah (batas bawah: %.2f MB/s, jumlah: %d, paralel: %d, alamat: %s)\n", MinUploadSpeed, count, workers, UploadURL)
+	bar_a := len(strconv.Itoa(count))
+	bar_b := "     "
+	for i := 0; i < bar_a; i++ {
+		bar_b += " "
+	}
+	bar := utils.NewBar(count, bar_b, "")
+	var (
+		wg   sync.WaitGroup
+		m    sync.Mutex
+		next int // Indeks IP berikutnya dalam antrian
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				m.Lock()
+				if next >= count || budgetExhausted() { // Batas data (-data-budget) tercapai, IP yang belum diuji dilewati
+					m.Unlock()
+					return
+				}
+				i := next
+				next++
+				m.Unlock()
+
+				speed := uploadHandler(speedSet[i].IP)
+				speedSet[i].UploadSpeed = speed
+				bar.Grow(1, "")
+				if speed >= MinUploadSpeed*1024*1024 && utils.FilterAllows(&speedSet[i], utils.StageUpload) { // Jika lebih tinggi dari batas bawah kecepatan unggah dan lolos [-filter], tambahkan ke array baru
+					m.Lock()
+					data = append(data, speedSet[i])
+					m.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	bar.Done()
+	if budgetExhausted() {
+		fmt.Printf("\n[Info] Batas data %s tercapai (terpakai %s), pengujian unggah dihentikan.\n", utils.FormatBytes(DataBudget), utils.FormatBytes(DataUsed()))
+	}
+	if len(data) == 0 { // Tidak ada data yang memenuhi batas kecepatan unggah, kembalikan semua data tes
+		data = speedSet
+	} else if MinUploadSpeed <= 0 { // IP yang tidak diuji (di luar [-dn] atau setelah batas data tercapai) tetap ada dalam hasil
+		data = append(data, speedSet[next:]...)
+	}
+	data.Sort()
+	return
+}
+
+// Body unggah: mengirim data sampai waktu pengujian habis, mencatat waktu mulai dan jumlah byte yang dikirim
+type uploadBody struct {
+	start   time.Time
+	timeout time.Duration
+	written int64
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	now := time.Now()
+	if b.start.IsZero() { // Waktu mulai dihitung saat data pertama dikirim (setelah koneksi dan TLS selesai)
+		b.start = now
+	}
+	if now.Sub(b.start) >= b.timeout || b.written >= maxUploadSize || budgetExhausted() {
+		return 0, io.EOF
+	}
+	n := copy(p, uploadChunk)
+	b.written += int64(n)
+	consumeData(int64(n))
+	return n, nil
+}
+
+// Mengembalikan kecepatan unggah
+func uploadHandler(ip *net.IPAddr) float64 {
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: getDialContext(ip)},
+		Timeout:   Timeout + uploadResponseWait,
+	}
+	body := &uploadBody{timeout: Timeout}
+	req, err := http.NewRequest(http.MethodPost, UploadURL, body)
+	if err != nil {
+		return 0.0
+	}
+	req.ContentLength = -1 // Ukuran data tidak diketahui sebelumnya, gunakan chunked transfer
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
+
+	response, err := client.Do(req)
+	if err != nil || body.start.IsZero() {
+		return 0.0
+	}
+	// Waktu selesai adalah saat server membalas (semua data telah diterima server)
+	elapsed := time.Since(body.start)
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return 0.0
+	}
+	return float64(body.written) / elapsed.Seconds()
+}
@@ -0,0 +1,103 @@
+package task
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/SonzaiEkkusu/Proxy-Finder/utils"
+)
+
+var (
+	// Verify memeriksa header identitas penyedia pada respons unduh (-verify)
+	Verify = false
+	// VerifySize adalah Content-Length yang diharapkan dari respons unduh, 0 berarti tidak diperiksa (-verify-size)
+	VerifySize int64
+	// VerifySHA256 adalah checksum SHA-256 file unduh yang diharapkan, hanya diperiksa jika file selesai diunduh (-verify-sha256)
+	VerifySHA256 string
+	// VerifyCertName adalah nama yang harus ada pada sertifikat TLS, dicocokkan dengan nama host sertifikat atau subject (-verify-cert)
+	VerifyCertName string
+)
+
+// Memvalidasi parameter verifikasi, dipanggil saat program dimulai
+func ParseVerify() error {
+	if VerifySHA256 != "" {
+		b, err := hex.DecodeString(VerifySHA256)
+		if err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("checksum [-verify-sha256 %s] tidak valid, harus berupa 64 karakter heksadesimal", VerifySHA256)
+		}
+		VerifySHA256 = strings.ToLower(VerifySHA256)
+	}
+	if VerifySize < 0 {
+		return fmt.Errorf("ukuran [-verify-size %d] tidak valid", VerifySize)
+	}
+	if Verify && provider.identity == nil {
+		fmt.Printf("[Tips] Penyedia %s tidak memiliki header identitas yang dapat diperiksa, [-verify] hanya memeriksa ukuran/checksum/sertifikat...\n", provider.Name)
+	}
+	return nil
+}
+
+// Memeriksa header dan sertifikat respons unduh, mengembalikan alasan kegagalan atau kosong jika lolos
+func verifyResponse(resp *http.Response) string {
+	if Verify && provider.identity != nil && !provider.identity(resp.Header) {
+		return fmt.Sprintf("header identitas %s tidak ada (Server: %s)", provider.Name, resp.Header.Get("Server"))
+	}
+	if VerifySize > 0 && resp.ContentLength != VerifySize {
+		return fmt.Sprintf("Content-Length %d, seharusnya %d", resp.ContentLength, VerifySize)
+	}
+	if VerifyCertName != "" {
+		if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+			return "respons tanpa TLS"
+		}
+		cert := resp.TLS.PeerCertificates[0]
+		if cert.VerifyHostname(VerifyCertName) != nil && !strings.Contains(cert.Subject.String(), VerifyCertName) {
+			return fmt.Sprintf("sertifikat TLS tidak cocok (%s)", cert.Subject.String())
+		}
+	}
+	return ""
+}
+
+// Menghitung checksum body unduh jika -verify-sha256 diisi
+type payloadVerifier struct {
+	h hash.Hash
+}
+
+func newPayloadVerifier() *payloadVerifier {
+	if VerifySHA256 == "" {
+		return &payloadVerifier{}
+	}
+	return &payloadVerifier{h: sha256.New()}
+}
+
+func (v *payloadVerifier) write(p []byte) {
+	if v.h != nil {
+		v.h.Write(p)
+	}
+}
+
+// Mengembalikan alasan kegagalan jika checksum tidak cocok
+func (v *payloadVerifier) check() string {
+	if v.h == nil {
+		return ""
+	}
+	if sum := hex.EncodeToString(v.h.Sum(nil)); sum != VerifySHA256 {
+		return fmt.Sprintf("checksum SHA-256 tidak cocok (%s...)", sum[:12])
+	}
+	return ""
+}
+
+// Menampilkan jumlah IP yang gagal verifikasi respons unduh
+func printVerifySummary(ipSet utils.PingDelaySet) {
+	invalid := 0
+	for _, v := range ipSet {
+		if v.VerifyError != "" {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		fmt.Printf("\n[Peringatan] %d IP gagal verifikasi respons unduh (kemungkinan dicegat atau diubah oleh jaringan), IP tersebut ditandai tidak valid.\n", invalid)
+	}
+}
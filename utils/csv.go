@@ -7,22 +7,25 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	defaultOutput         = "result.csv"
-	maxDelay              = 9999 * time.Millisecond
-	minDelay              = 0 * time.Millisecond
-	maxLossRate   float32 = 1.0
+	maxDelay            = 9999 * time.Millisecond
+	minDelay            = 0 * time.Millisecond
+	maxLossRate float32 = 1.0
 )
 
 var (
 	InputMaxDelay    = maxDelay
 	InputMinDelay    = minDelay
 	InputMaxLossRate = maxLossRate
-	Output           = defaultOutput
 	PrintNum         = 10
+	// SeriesOutput adalah file deret waktu kecepatan unduh per IP, kosong berarti tidak ditulis (-series)
+	SeriesOutput = ""
+	// SpeedSortKey adalah dasar pengurutan hasil kecepatan: download, upload, atau sum (unduh + unggah)
+	SpeedSortKey = "download"
 )
 
 // Apakah akan mencetak hasil pengujian
@@ -30,60 +33,133 @@ func NoPrintResult() bool {
 	return PrintNum == 0
 }
 
-// Apakah akan menulis hasil ke file
-func noOutput() bool {
-	return Output == "" || Output == " "
-}
-
 type PingData struct {
 	IP       *net.IPAddr
+	Port     int  // Port pengujian
+	TLS      bool // Apakah pengujian melalui TLS
 	Sended   int
 	Received int
 	Delay    time.Duration
+	Jitter   time.Duration // Rata-rata selisih latensi antar pengujian berturut-turut
+	Colo     string
+	City     string
+	Country  string
+	Region   string
+}
+
+// Statistik kecepatan satu pengujian, semua kecepatan dalam byte/detik
+type SpeedStats struct {
+	Average   float64       // Total byte / total waktu
+	Sustained float64       // Kecepatan setelah waktu pemanasan (TCP slow-start)
+	Peak      float64       // Kecepatan tertinggi dalam jendela geser
+	Bytes     int64         // Total byte yang diterima
+	Elapsed   time.Duration // Durasi pengujian
+	Series    []SeriesPoint // Byte yang diterima per potongan waktu (durasi pengujian / 100)
+	Throttled bool          // Kecepatan turun tajam di akhir pengujian dibandingkan kecepatan puncak (dibatasi/macet)
+}
+
+// Satu titik deret waktu kecepatan unduh: byte yang diterima dalam potongan waktu yang berakhir pada At
+type SeriesPoint struct {
+	At    time.Duration
+	Bytes int64
 }
 
 type CloudflareIPData struct {
 	*PingData
 	lossRate      float32
-	DownloadSpeed float64
-}
-
-// Menghitung tingkat kehilangan paket
+	DownloadSpeed float64 // Kecepatan unduh utama (sama dengan Download.Sustained)
+	Download      SpeedStats
+	UploadSpeed   float64
+	ConnSpeeds    []float64         // Kecepatan setiap koneksi saat pengujian unduh multi-koneksi (-dc)
+	VerifyError   string            // Alasan respons unduh gagal verifikasi, kosong jika valid
+	Aborted       bool              // Pengujian unduh dihentikan lebih awal, kecepatan adalah kecepatan parsial
+	DownloadURL   string            // Alamat unduh yang menghasilkan pengukuran (-url dapat berisi beberapa alamat)
+	DownloadError string            // Alasan kegagalan pengujian unduh (dial, tls, timeout, reset, status N, size), kosong jika berhasil
+	LoadedDelay   time.Duration     // Median latensi TCP ke IP yang sama selama pengujian unduh (latensi saat beban)
+	IdleDelay     time.Duration     // Median latensi TCP ke IP yang sama tepat sebelum pengujian unduh (latensi tanpa beban)
+	Previous      *CloudflareIPData // Hasil sebelumnya untuk IP yang sama saat pengujian ulang (-from-result), nil jika tidak ada
+	Score         float64           // Skor gabungan 0~100 relatif terhadap IP lain dalam hasil yang sama (lihat computeScores)
+}
+
+// Menghitung tingkat kehilangan paket, 0 jika pengujian latensi tidak dijalankan (Sended 0, misalnya hasil pencari proxy)
 func (cf *CloudflareIPData) getLossRate() float32 {
-	if cf.lossRate == 0 {
+	if cf.lossRate == 0 && cf.Sended > 0 {
 		pingLost := cf.Sended - cf.Received
 		cf.lossRate = float32(pingLost) / float32(cf.Sended)
 	}
 	return cf.lossRate
 }
 
+// Kenaikan latensi saat beban dibandingkan latensi tanpa beban yang diukur dengan probe yang sama,
+// atau latensi rata-rata untuk hasil lama tanpa latensi tanpa beban, 0 jika latensi saat beban tidak diukur
+func (cf *CloudflareIPData) LoadedDelta() time.Duration {
+	if cf.LoadedDelay <= 0 {
+		return 0
+	}
+	if cf.IdleDelay > 0 {
+		return cf.LoadedDelay - cf.IdleDelay
+	}
+	return cf.LoadedDelay - cf.Delay
+}
+
+// Nilai setiap kolom sesuai skema file hasil CSV (lihat csvColumns)
 func (cf *CloudflareIPData) toString() []string {
-	result := make([]string, 6)
-	result[0] = cf.IP.String()
-	result[1] = strconv.Itoa(cf.Sended)
-	result[2] = strconv.Itoa(cf.Received)
-	result[3] = strconv.FormatFloat(float64(cf.getLossRate()), 'f', 2, 32)
-	result[4] = strconv.FormatFloat(cf.Delay.Seconds()*1000, 'f', 2, 32)
-	result[5] = strconv.FormatFloat(cf.DownloadSpeed/1024/1024, 'f', 2, 32)
+	result := make([]string, len(csvColumns))
+	for i, c := range csvColumns {
+		result[i] = c.write(cf)
+	}
 	return result
 }
 
-func ExportCsv(data []CloudflareIPData) {
-	if noOutput() || len(data) == 0 {
-		return
+// Kecepatan setiap koneksi (MB/s) dipisahkan dengan "/", kosong jika hanya satu koneksi
+func (cf *CloudflareIPData) connSpeedsString() string {
+	if len(cf.ConnSpeeds) <= 1 {
+		return ""
+	}
+	speeds := make([]string, len(cf.ConnSpeeds))
+	for i, v := range cf.ConnSpeeds {
+		speeds[i] = strconv.FormatFloat(v/1024/1024, 'f', 2, 32)
 	}
-	fp, err := os.Create(Output)
+	return strings.Join(speeds, "/")
+}
+
+func exportCsv(file string, data []CloudflareIPData) {
+	fp, err := os.Create(file)
 	if err != nil {
-		log.Fatalf("Gagal membuat file [%s]: %v", Output, err)
+		log.Fatalf("Gagal membuat file [%s]: %v", file, err)
 		return
 	}
 	defer fp.Close()
 	w := csv.NewWriter(fp) // Membuat stream penulisan file baru
-	_ = w.Write([]string{"Alamat IP", "Terkirim", "Diterima", "Tingkat Kehilangan Paket", "Rata-rata Latensi", "Kecepatan Unduh (MB/s)"})
+	_ = w.Write(csvHeader())
 	_ = w.WriteAll(convertToString(data))
 	w.Flush()
 }
 
+// Menulis deret waktu kecepatan unduh setiap IP (satu baris per potongan waktu) agar dapat dibuat grafik
+func ExportSeries(data []CloudflareIPData) {
+	if SeriesOutput == "" || len(data) == 0 {
+		return
+	}
+	fp, err := os.Create(SeriesOutput)
+	if err != nil {
+		log.Fatalf("Gagal membuat file [%s]: %v", SeriesOutput, err)
+		return
+	}
+	defer fp.Close()
+	w := csv.NewWriter(fp)
+	_ = w.Write([]string{"Alamat IP", "Waktu (detik)", "Byte", "Kecepatan (MB/s)"})
+	for _, v := range data {
+		var prev time.Duration
+		for _, p := range v.Download.Series {
+			speed := float64(p.Bytes) / (p.At - prev).Seconds() / 1024 / 1024
+			prev = p.At
+			_ = w.Write([]string{v.IP.String(), strconv.FormatFloat(p.At.Seconds(), 'f', 3, 64), strconv.FormatInt(p.Bytes, 10), strconv.FormatFloat(speed, 'f', 2, 64)})
+		}
+	}
+	w.Flush()
+}
+
 func convertToString(data []CloudflareIPData) [][]string {
 	result := make([][]string, 0)
 	for _, v := range data {
@@ -95,44 +171,13 @@ func convertToString(data []CloudflareIPData) [][]string {
 // Pengurutan latensi dan kehilangan paket
 type PingDelaySet []CloudflareIPData
 
-// Penyaringan berdasarkan kondisi latensi
-func (s PingDelaySet) FilterDelay() (data PingDelaySet) {
-	if InputMaxDelay > maxDelay || InputMinDelay < minDelay { // Ketika kondisi latensi yang dimasukkan tidak dalam batasan default, tidak dilakukan penyaringan
-		return s
-	}
-	if InputMaxDelay == maxDelay && InputMinDelay == minDelay { // Ketika kondisi latensi yang dimasukkan adalah nilai default, tidak dilakukan penyaringan
-		return s
-	}
-	for _, v := range s {
-		if v.Delay > InputMaxDelay { // Batas atas rata-rata latensi, jika latensi melebihi nilai maksimum yang ditetapkan, data berikutnya tidak memenuhi syarat, langsung keluar dari loop
-			break
-		}
-		if v.Delay < InputMinDelay { // Batas bawah rata-rata latensi, jika latensi kurang dari nilai minimum yang ditetapkan, tidak memenuhi syarat, lewati
-			continue
-		}
-		data = append(data, v) // Jika latensi memenuhi syarat, tambahkan ke array baru
-	}
-	return
-}
-
-// Penyaringan berdasarkan kondisi kehilangan paket
-func (s PingDelaySet) FilterLossRate() (data PingDelaySet) {
-	if InputMaxLossRate >= maxLossRate { // Ketika kondisi kehilangan paket yang dimasukkan adalah nilai default, tidak dilakukan penyaringan
-		return s
-	}
-	for _, v := range s {
-		if v.getLossRate() > InputMaxLossRate { // Batas atas tingkat kehilangan paket
-			break
-		}
-		data = append(data, v) // Jika tingkat kehilangan paket memenuhi syarat, tambahkan ke array baru
-	}
-	return
-}
-
 func (s PingDelaySet) Len() int {
 	return len(s)
 }
 func (s PingDelaySet) Less(i, j int) bool {
+	if less, ok := sortLess(&s[i], &s[j]); ok {
+		return less
+	}
 	iRate, jRate := s[i].getLossRate(), s[j].getLossRate()
 	if iRate != jRate {
 		return iRate < jRate
@@ -150,7 +195,10 @@ func (s DownloadSpeedSet) Len() int {
 	return len(s)
 }
 func (s DownloadSpeedSet) Less(i, j int) bool {
-	return s[i].DownloadSpeed > s[j].DownloadSpeed
+	if less, ok := sortLess(&s[i], &s[j]); ok {
+		return less
+	}
+	return s[i].speed() > s[j].speed()
 }
 func (s DownloadSpeedSet) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
@@ -168,20 +216,20 @@ func (s DownloadSpeedSet) Print() {
 	if len(dateString) < PrintNum {  // Jika panjang array IP (jumlah IP) kurang dari jumlah cetakan, maka jumlah cetakan diubah menjadi jumlah IP
 		PrintNum = len(dateString)
 	}
-	headFormat := "%-16s%-5s%-5s%-5s%-6s%-11s\n"
-	dataFormat := "%-18s%-8s%-8s%-8s%-10s%-15s\n"
+	headFormat := "%-16s%-5s%-5s%-5s%-6s%-11s%-11s%-5s\n"
+	dataFormat := "%-18s%-8s%-8s%-8s%-10s%-15s%-15s%-5s\n"
 	for i := 0; i < PrintNum; i++ { // Jika IP yang akan dicetak mencakup IPv6, maka perlu menyesuaikan spasi
 		if len(dateString[i][0]) > 15 {
-			headFormat = "%-40s%-5s%-5s%-5s%-6s%-11s\n"
-			dataFormat = "%-42s%-8s%-8s%-8s%-10s%-15s\n"
+			headFormat = "%-40s%-5s%-5s%-5s%-6s%-11s%-11s%-5s\n"
+			dataFormat = "%-42s%-8s%-8s%-8s%-10s%-15s%-15s%-5s\n"
 			break
 		}
 	}
-	fmt.Printf(headFormat, "Alamat IP", "Terkirim", "Diterima", "Tingkat Kehilangan Paket", "Rata-rata Latensi", "Kecepatan Unduh (MB/s)")
+	fmt.Printf(headFormat, "Alamat IP", "Terkirim", "Diterima", "Tingkat Kehilangan Paket", "Rata-rata Latensi", "Kecepatan Unduh (MB/s)", "Kecepatan Unggah (MB/s)", "Colo")
 	for i := 0; i < PrintNum; i++ {
-		fmt.Printf(dataFormat, dateString[i][0], dateString[i][1], dateString[i][2], dateString[i][3], dateString[i][4], dateString[i][5])
+		fmt.Printf(dataFormat, dateString[i][0], dateString[i][1], dateString[i][2], dateString[i][3], dateString[i][4], dateString[i][5], dateString[i][6], dateString[i][7])
 	}
-	if !noOutput() {
-		fmt.Printf("\nHasil uji kecepatan lengkap telah ditulis ke file %v, Anda dapat menggunakan Notepad/Perangkat Lunak Spreadsheet untuk melihatnya.\n", Output)
+	if files := outputFiles(); len(files) > 0 {
+		fmt.Printf("\nHasil uji kecepatan lengkap telah ditulis ke file %v, Anda dapat menggunakan Notepad/Perangkat Lunak Spreadsheet untuk melihatnya.\n", strings.Join(files, ", "))
 	}
 }
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Satu IP dalam perbandingan dua file hasil, peringkat dimulai dari 1 dan 0 berarti IP tidak ada dalam file tersebut
+type DiffEntry struct {
+	IP               string  `json:"ip"`
+	Port             int     `json:"port"`
+	OldRank          int     `json:"old_rank"`
+	NewRank          int     `json:"new_rank"`
+	RankChange       int     `json:"rank_change"` // Positif berarti naik peringkat
+	OldLatencyNs     int64   `json:"old_latency_ns"`
+	NewLatencyNs     int64   `json:"new_latency_ns"`
+	LatencyDeltaNs   int64   `json:"latency_delta_ns"`
+	OldDownloadBps   float64 `json:"old_download_bps"`
+	NewDownloadBps   float64 `json:"new_download_bps"`
+	DownloadDeltaBps float64 `json:"download_delta_bps"`
+	OldColo          string  `json:"old_colo"`
+	NewColo          string  `json:"new_colo"`
+	ColoChanged      bool    `json:"colo_changed"`
+}
+
+// Hasil perbandingan dua file hasil (lama dan baru)
+type ResultDiff struct {
+	SchemaVersion int         `json:"schema_version"`
+	Old           RunInfo     `json:"old"`
+	New           RunInfo     `json:"new"`
+	Appeared      []DiffEntry `json:"appeared"`    // Hanya ada di hasil baru
+	Disappeared   []DiffEntry `json:"disappeared"` // Hanya ada di hasil lama
+	Common        []DiffEntry `json:"common"`      // Ada di kedua hasil, berurutan berdasarkan peringkat baru
+}
+
+// Kunci IP dalam perbandingan, dengan withPort IP yang sama dengan port berbeda dianggap berbeda
+func diffKey(cf *CloudflareIPData, withPort bool) string {
+	if !withPort {
+		return cf.IP.String()
+	}
+	return cf.IP.String() + "/" + strconv.Itoa(cf.Port)
+}
+
+// Apakah semua hasil mencatat port, file hasil format lama tidak memiliki kolom port (port 0)
+func hasPorts(data []CloudflareIPData) bool {
+	for i := range data {
+		if data[i].Port == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffResults membandingkan hasil lama (before) dan baru (after) berdasarkan IP dan port, urutan data adalah peringkatnya.
+// Jika salah satu file tidak mencatat port (format lama), IP dibandingkan tanpa port agar IP yang sama tidak muncul sebagai baru sekaligus hilang
+func DiffResults(before, after []CloudflareIPData) (d ResultDiff) {
+	d.SchemaVersion = SchemaVersion
+	d.Appeared, d.Disappeared, d.Common = []DiffEntry{}, []DiffEntry{}, []DiffEntry{}
+	withPort := hasPorts(before) && hasPorts(after)
+	oldRank := make(map[string]int, len(before))
+	for i := range before {
+		if _, ok := oldRank[diffKey(&before[i], withPort)]; !ok {
+			oldRank[diffKey(&before[i], withPort)] = i + 1
+		}
+	}
+	seen := make(map[string]bool, len(after))
+	for i := range after {
+		n := &after[i]
+		key := diffKey(n, withPort)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		e := DiffEntry{IP: n.IP.String(), Port: n.Port, NewRank: i + 1, NewLatencyNs: n.Delay.Nanoseconds(), NewDownloadBps: n.DownloadSpeed, NewColo: n.Colo}
+		rank, ok := oldRank[key]
+		if !ok {
+			d.Appeared = append(d.Appeared, e)
+			continue
+		}
+		o := &before[rank-1]
+		e.OldRank, e.RankChange = rank, rank-e.NewRank
+		e.OldLatencyNs, e.LatencyDeltaNs = o.Delay.Nanoseconds(), (n.Delay - o.Delay).Nanoseconds()
+		e.OldDownloadBps, e.DownloadDeltaBps = o.DownloadSpeed, n.DownloadSpeed-o.DownloadSpeed
+		e.OldColo, e.ColoChanged = o.Colo, o.Colo != n.Colo
+		d.Common = append(d.Common, e)
+	}
+	for key, rank := range oldRank {
+		if !seen[key] {
+			o := &before[rank-1]
+			d.Disappeared = append(d.Disappeared, DiffEntry{IP: o.IP.String(), Port: o.Port, OldRank: rank, OldLatencyNs: o.Delay.Nanoseconds(), OldDownloadBps: o.DownloadSpeed, OldColo: o.Colo})
+		}
+	}
+	sort.Slice(d.Disappeared, func(i, j int) bool { return d.Disappeared[i].OldRank < d.Disappeared[j].OldRank })
+	return
+}
+
+// Menampilkan perbandingan dalam bentuk tabel
+func (d ResultDiff) Print() {
+	moved, coloChanged := 0, 0
+	for _, e := range d.Common {
+		if e.RankChange != 0 {
+			moved++
+		}
+		if e.ColoChanged {
+			coloChanged++
+		}
+	}
+	fmt.Printf("Baru: %d, Hilang: %d, Pindah peringkat: %d, Colo berubah: %d, Tetap ada: %d\n", len(d.Appeared), len(d.Disappeared), moved, coloChanged, len(d.Common))
+
+	format := "%-42s%-16s%-28s%-28s%-12s\n"
+	if len(d.Appeared) > 0 {
+		fmt.Println("\nIP baru:")
+		fmt.Printf(format, "Alamat IP", "Peringkat", "Rata-rata Latensi", "Kecepatan Unduh (MB/s)", "Colo")
+		for _, e := range d.Appeared {
+			fmt.Printf(format, e.IP, strconv.Itoa(e.NewRank), diffMillis(0, e.NewLatencyNs), diffMBps(0, e.NewDownloadBps), e.NewColo)
+		}
+	}
+	if len(d.Disappeared) > 0 {
+		fmt.Println("\nIP hilang:")
+		fmt.Printf(format, "Alamat IP", "Peringkat", "Rata-rata Latensi", "Kecepatan Unduh (MB/s)", "Colo")
+		for _, e := range d.Disappeared {
+			fmt.Printf(format, e.IP, strconv.Itoa(e.OldRank), diffMillis(0, e.OldLatencyNs), diffMBps(0, e.OldDownloadBps), e.OldColo)
+		}
+	}
+	if len(d.Common) > 0 {
+		fmt.Println("\nIP tetap ada (lama -> baru):")
+		fmt.Printf(format, "Alamat IP", "Peringkat", "Rata-rata Latensi", "Kecepatan Unduh (MB/s)", "Colo")
+		for _, e := range d.Common {
+			rank := fmt.Sprintf("%d -> %d", e.OldRank, e.NewRank)
+			if e.RankChange != 0 {
+				rank += fmt.Sprintf(" (%+d)", e.RankChange)
+			}
+			colo := e.NewColo
+			if e.ColoChanged {
+				colo = e.OldColo + " -> " + e.NewColo
+			}
+			fmt.Printf(format, e.IP, rank, diffMillis(e.OldLatencyNs, e.NewLatencyNs), diffMBps(e.OldDownloadBps, e.NewDownloadBps), colo)
+		}
+	}
+}
+
+// Latensi lama -> baru (selisih) dalam milidetik, hanya nilai baru jika lama 0
+func diffMillis(before, after int64) string {
+	if before == 0 {
+		return formatMillis(time.Duration(after))
+	}
+	return fmt.Sprintf("%s -> %s (%+.2f)", formatMillis(time.Duration(before)), formatMillis(time.Duration(after)), float64(after-before)/1e6)
+}
+
+// Kecepatan lama -> baru (selisih) dalam MB/s, hanya nilai baru jika lama 0
+func diffMBps(before, after float64) string {
+	if before == 0 {
+		return formatMBps(after)
+	}
+	return fmt.Sprintf("%s -> %s (%+.2f)", formatMBps(before), formatMBps(after), (after-before)/1024/1024)
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func diffData(port int, ips ...string) []CloudflareIPData {
+	data := make([]CloudflareIPData, len(ips))
+	for i, ip := range ips {
+		data[i] = CloudflareIPData{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP(ip)}, Port: port}}
+	}
+	return data
+}
+
+func TestDiffResults(t *testing.T) {
+	tests := []struct {
+		name                          string
+		before, after                 []CloudflareIPData
+		appeared, disappeared, common int
+	}{
+		{"same ports", diffData(443, "1.1.1.1", "1.0.0.1"), diffData(443, "1.0.0.1", "1.1.1.2"), 1, 1, 1},
+		{"different ports", diffData(443, "1.1.1.1"), diffData(8443, "1.1.1.1"), 1, 1, 0},
+		{"old file without ports", diffData(0, "1.1.1.1", "1.0.0.1"), diffData(443, "1.0.0.1", "1.1.1.1"), 0, 0, 2},
+		{"new file without ports", diffData(443, "1.1.1.1"), diffData(0, "1.1.1.1", "1.1.1.2"), 1, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DiffResults(tt.before, tt.after)
+			if len(d.Appeared) != tt.appeared || len(d.Disappeared) != tt.disappeared || len(d.Common) != tt.common {
+				t.Errorf("appeared/disappeared/common = %d/%d/%d, want %d/%d/%d",
+					len(d.Appeared), len(d.Disappeared), len(d.Common), tt.appeared, tt.disappeared, tt.common)
+			}
+		})
+	}
+
+	d := DiffResults(diffData(0, "1.1.1.1", "1.0.0.1"), diffData(443, "1.0.0.1", "1.1.1.1"))
+	if e := d.Common[0]; e.IP != "1.0.0.1" || e.OldRank != 2 || e.NewRank != 1 || e.RankChange != 1 {
+		t.Errorf("Common[0] = %+v, want 1.0.0.1 rank 2 -> 1", e)
+	}
+}
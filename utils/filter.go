@@ -0,0 +1,560 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FilterExpr adalah ekspresi filter hasil, contoh: loss < 0.1 && delay < 150ms && colo in ["SIN","HKG"] && port == 443 (-filter)
+var FilterExpr string
+
+// Tahap pengujian tempat filter dievaluasi, field yang belum diukur pada suatu tahap (misalnya speed sebelum pengujian unduh)
+// tidak menyaring IP pada tahap tersebut dan baru dievaluasi pada tahap berikutnya
+type FilterStage int
+
+const (
+	StagePing     FilterStage = iota // Setelah pengujian latensi
+	StageColo                        // Setelah deteksi colo
+	StageDownload                    // Setelah pengujian unduh setiap IP
+	StageUpload                      // Setelah pengujian unggah setiap IP
+)
+
+// Filter gabungan: [-filter] dan [-tl] [-tll] [-tlr], nil berarti tidak ada filter
+var resultFilter filterNode
+
+// Jenis nilai field
+type fieldKind int
+
+const (
+	kindNumber   fieldKind = iota // Angka, boleh diakhiri % (dibagi 100)
+	kindDuration                  // Durasi: 150ms, 1.5s, angka tanpa satuan dalam milidetik
+	kindSpeed                     // Kecepatan dalam MB/s
+	kindString                    // Teks dalam tanda kutip, tidak peka huruf besar/kecil
+	kindIP                        // Alamat IP atau rentang CIDR dalam tanda kutip
+	kindBool                      // true atau false
+)
+
+// Field hasil yang dapat digunakan dalam filter
+type filterField struct {
+	kind    fieldKind
+	stage   FilterStage // Tahap saat nilai field mulai tersedia
+	single  bool        // Nilai diukur sebagai float32 (kehilangan paket), literal dibulatkan ke float32 agar 1/10 <= 0.1 bernilai true
+	num     func(cf *CloudflareIPData) float64
+	str     func(cf *CloudflareIPData) string
+	boolean func(cf *CloudflareIPData) bool
+}
+
+var filterFields = map[string]*filterField{
+	"ip":           {kind: kindIP, stage: StagePing, str: func(cf *CloudflareIPData) string { return cf.IP.String() }},
+	"port":         {kind: kindNumber, stage: StagePing, num: func(cf *CloudflareIPData) float64 { return float64(cf.Port) }},
+	"tls":          {kind: kindBool, stage: StagePing, boolean: func(cf *CloudflareIPData) bool { return cf.TLS }},
+	"sent":         {kind: kindNumber, stage: StagePing, num: func(cf *CloudflareIPData) float64 { return float64(cf.Sended) }},
+	"received":     {kind: kindNumber, stage: StagePing, num: func(cf *CloudflareIPData) float64 { return float64(cf.Received) }},
+	"loss":         {kind: kindNumber, stage: StagePing, single: true, num: func(cf *CloudflareIPData) float64 { return float64(cf.getLossRate()) }},
+	"delay":        {kind: kindDuration, stage: StagePing, num: func(cf *CloudflareIPData) float64 { return float64(cf.Delay) }},
+	"jitter":       {kind: kindDuration, stage: StagePing, num: func(cf *CloudflareIPData) float64 { return float64(cf.Jitter) }},
+	"colo":         {kind: kindString, stage: StageColo, str: func(cf *CloudflareIPData) string { return cf.Colo }},
+	"city":         {kind: kindString, stage: StageColo, str: func(cf *CloudflareIPData) string { return cf.City }},
+	"country":      {kind: kindString, stage: StageColo, str: func(cf *CloudflareIPData) string { return cf.Country }},
+	"region":       {kind: kindString, stage: StageColo, str: func(cf *CloudflareIPData) string { return cf.Region }},
+	"speed":        {kind: kindSpeed, stage: StageDownload, num: func(cf *CloudflareIPData) float64 { return cf.DownloadSpeed }},
+	"peak":         {kind: kindSpeed, stage: StageDownload, num: func(cf *CloudflareIPData) float64 { return cf.Download.Peak }},
+	"loaded_delay": {kind: kindDuration, stage: StageDownload, num: func(cf *CloudflareIPData) float64 { return float64(cf.LoadedDelay) }},
+	"throttled":    {kind: kindBool, stage: StageDownload, boolean: func(cf *CloudflareIPData) bool { return cf.Download.Throttled }},
+	"aborted":      {kind: kindBool, stage: StageDownload, boolean: func(cf *CloudflareIPData) bool { return cf.Aborted }},
+	"upload":       {kind: kindSpeed, stage: StageUpload, num: func(cf *CloudflareIPData) float64 { return cf.UploadSpeed }},
+}
+
+// Nama lain field
+var filterAliases = map[string]string{"latency": "delay", "download": "speed"}
+
+// Memvalidasi [-filter] dan menggabungkannya dengan [-tl] [-tll] [-tlr], dipanggil saat program dimulai setelah batas latensi/kehilangan paket ditentukan
+func ParseFilter() error {
+	var nodes []filterNode
+	if strings.TrimSpace(FilterExpr) != "" {
+		node, err := parseFilter(FilterExpr)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, node)
+	}
+	// Kondisi dari [-tl] [-tll] [-tlr], tidak dilakukan penyaringan jika nilainya default atau di luar batasan default.
+	// Node dibuat langsung dari nilai batas (tanpa diubah ke teks) agar perbandingan sama persis dengan penyaringan sebelumnya
+	if InputMaxDelay <= maxDelay && InputMinDelay >= minDelay && (InputMaxDelay != maxDelay || InputMinDelay != minDelay) {
+		nodes = append(nodes,
+			&compareNode{field: filterFields["delay"], op: "<=", values: []filterValue{{num: float64(InputMaxDelay)}}},
+			&compareNode{field: filterFields["delay"], op: ">=", values: []filterValue{{num: float64(InputMinDelay)}}})
+	}
+	if InputMaxLossRate < maxLossRate {
+		nodes = append(nodes, &compareNode{field: filterFields["loss"], op: "<=", values: []filterValue{{num: float64(InputMaxLossRate)}}})
+	}
+	resultFilter = nil
+	for _, node := range nodes {
+		if resultFilter == nil {
+			resultFilter = node
+		} else {
+			resultFilter = &logicNode{and: true, left: resultFilter, right: node}
+		}
+	}
+	return nil
+}
+
+// Apakah filter menggunakan field lokasi (colo, kota, negara, wilayah), jika ya deteksi colo perlu dijalankan
+func FilterUsesLocation() bool {
+	return resultFilter != nil && resultFilter.usesStage(StageColo)
+}
+
+// Apakah IP lolos filter pada tahap yang ditentukan
+func FilterAllows(cf *CloudflareIPData, stage FilterStage) bool {
+	return resultFilter == nil || resultFilter.eval(cf, stage) != triFalse
+}
+
+// Penyaringan berdasarkan filter [-filter] [-tl] [-tll] [-tlr] pada tahap yang ditentukan
+func (s PingDelaySet) Filter(stage FilterStage) (data PingDelaySet) {
+	if resultFilter == nil {
+		return s
+	}
+	for i := range s {
+		if FilterAllows(&s[i], stage) {
+			data = append(data, s[i])
+		}
+	}
+	return
+}
+
+// Logika tiga nilai: field yang belum diukur pada suatu tahap bernilai unknown, IP hanya dibuang jika hasilnya false
+type tri int8
+
+const (
+	triFalse tri = iota
+	triTrue
+	triUnknown
+)
+
+func toTri(b bool) tri {
+	if b {
+		return triTrue
+	}
+	return triFalse
+}
+
+type filterNode interface {
+	eval(cf *CloudflareIPData, stage FilterStage) tri
+	usesStage(stage FilterStage) bool
+}
+
+// a && b atau a || b
+type logicNode struct {
+	and         bool
+	left, right filterNode
+}
+
+func (n *logicNode) eval(cf *CloudflareIPData, stage FilterStage) tri {
+	l, r := n.left.eval(cf, stage), n.right.eval(cf, stage)
+	if n.and {
+		if l == triFalse || r == triFalse {
+			return triFalse
+		}
+		if l == triUnknown || r == triUnknown {
+			return triUnknown
+		}
+		return triTrue
+	}
+	if l == triTrue || r == triTrue {
+		return triTrue
+	}
+	if l == triUnknown || r == triUnknown {
+		return triUnknown
+	}
+	return triFalse
+}
+
+func (n *logicNode) usesStage(stage FilterStage) bool {
+	return n.left.usesStage(stage) || n.right.usesStage(stage)
+}
+
+// !a
+type notNode struct {
+	x filterNode
+}
+
+func (n *notNode) eval(cf *CloudflareIPData, stage FilterStage) tri {
+	switch n.x.eval(cf, stage) {
+	case triTrue:
+		return triFalse
+	case triFalse:
+		return triTrue
+	}
+	return triUnknown
+}
+
+func (n *notNode) usesStage(stage FilterStage) bool {
+	return n.x.usesStage(stage)
+}
+
+// Nilai literal yang sudah dikonversi sesuai jenis field
+type filterValue struct {
+	num     float64
+	str     string
+	boolean bool
+	cidr    *net.IPNet
+}
+
+// field op nilai, field in [nilai, ...] atau field boolean saja
+type compareNode struct {
+	field  *filterField
+	op     string // ==, !=, <, <=, >, >=, in
+	values []filterValue
+}
+
+func (n *compareNode) eval(cf *CloudflareIPData, stage FilterStage) tri {
+	f := n.field
+	if stage < f.stage && (f.str == nil || f.str(cf) == "") { // Belum diukur (colo dari HTTPing sudah tersedia sebelum deteksi colo)
+		return triUnknown
+	}
+	if n.op == "in" {
+		for _, v := range n.values {
+			if f.equal(cf, v) {
+				return triTrue
+			}
+		}
+		return triFalse
+	}
+	v := n.values[0]
+	switch n.op {
+	case "==":
+		return toTri(f.equal(cf, v))
+	case "!=":
+		return toTri(!f.equal(cf, v))
+	}
+	x := f.num(cf)
+	switch n.op {
+	case "<":
+		return toTri(x < v.num)
+	case "<=":
+		return toTri(x <= v.num)
+	case ">":
+		return toTri(x > v.num)
+	}
+	return toTri(x >= v.num)
+}
+
+func (n *compareNode) usesStage(stage FilterStage) bool {
+	return n.field.stage == stage
+}
+
+func (f *filterField) equal(cf *CloudflareIPData, v filterValue) bool {
+	switch f.kind {
+	case kindString:
+		return strings.EqualFold(f.str(cf), v.str)
+	case kindIP:
+		if v.cidr != nil {
+			return v.cidr.Contains(cf.IP.IP)
+		}
+		return cf.IP.IP.Equal(net.ParseIP(v.str))
+	case kindBool:
+		return f.boolean(cf) == v.boolean
+	}
+	return f.num(cf) == v.num
+}
+
+// Token ekspresi filter
+type filterToken struct {
+	kind string // ident, number, string, op, eof
+	text string
+	pos  int // Posisi karakter dalam ekspresi (dimulai dari 0)
+}
+
+func lexFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(runes) {
+				return nil, filterError(expr, i, "teks tidak ditutup dengan tanda kutip")
+			}
+			text, err := strconv.Unquote(string(runes[i : j+1]))
+			if err != nil {
+				return nil, filterError(expr, i, "teks tidak valid")
+			}
+			tokens = append(tokens, filterToken{"string", text, i})
+			i = j + 1
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || unicode.IsLetter(runes[j]) || runes[j] == '%') {
+				j++
+			}
+			tokens = append(tokens, filterToken{"number", string(runes[i:j]), i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, filterToken{"ident", string(runes[i:j]), i})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","} {
+				if strings.HasPrefix(string(runes[i:]), o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, filterError(expr, i, fmt.Sprintf("karakter [%c] tidak dikenal", c))
+			}
+			tokens = append(tokens, filterToken{"op", op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, filterToken{"eof", "akhir ekspresi", len(runes)}), nil
+}
+
+// Kesalahan sintaks dengan penanda posisi di bawah ekspresi
+func filterError(expr string, pos int, msg string) error {
+	return fmt.Errorf("filter [-filter] tidak valid pada posisi %d: %s\n    %s\n    %s^", pos+1, msg, expr, strings.Repeat(" ", pos))
+}
+
+// Parser rekursif, prioritas operator: ! lalu && lalu ||
+type filterParser struct {
+	expr   string
+	tokens []filterToken
+	i      int
+}
+
+func parseFilter(expr string) (filterNode, error) {
+	tokens, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{expr: expr, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, p.errorf(t, "diharapkan && atau ||, bukan [%s]", t.text)
+	}
+	return node, nil
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.i]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.i]
+	if t.kind != "eof" {
+		p.i++
+	}
+	return t
+}
+
+func (p *filterParser) errorf(t filterToken, format string, a ...interface{}) error {
+	return filterError(p.expr, t.pos, fmt.Sprintf(format, a...))
+}
+
+func (p *filterParser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == "op" && t.text == text
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.isOp("||") {
+		p.next()
+		var right filterNode
+		if right, err = p.parseAnd(); err == nil {
+			left = &logicNode{and: false, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	for err == nil && p.isOp("&&") {
+		p.next()
+		var right filterNode
+		if right, err = p.parseUnary(); err == nil {
+			left = &logicNode{and: true, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.isOp("!") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x}, nil
+	}
+	if p.isOp("(") {
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.errorf(p.peek(), "diharapkan ), bukan [%s]", p.peek().text)
+		}
+		p.next()
+		return node, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *filterParser) parseCompare() (filterNode, error) {
+	t := p.next()
+	if t.kind != "ident" {
+		return nil, p.errorf(t, "diharapkan nama field, bukan [%s]", t.text)
+	}
+	name := strings.ToLower(t.text)
+	if alias, ok := filterAliases[name]; ok {
+		name = alias
+	}
+	field, ok := filterFields[name]
+	if !ok {
+		return nil, p.errorf(t, "field [%s] tidak dikenal, pilihan: %s", t.text, filterFieldNames())
+	}
+
+	op := p.peek()
+	switch {
+	case op.kind == "ident" && strings.ToLower(op.text) == "in":
+		p.next()
+		if !p.isOp("[") {
+			return nil, p.errorf(p.peek(), "operator in memerlukan daftar, contoh: %s in [%s]", t.text, exampleValue(field.kind))
+		}
+		p.next()
+		node := &compareNode{field: field, op: "in"}
+		for {
+			v, err := p.parseValue(field, t.text)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, v)
+			if p.isOp("]") {
+				p.next()
+				return node, nil
+			}
+			if !p.isOp(",") {
+				return nil, p.errorf(p.peek(), "diharapkan , atau ], bukan [%s]", p.peek().text)
+			}
+			p.next()
+		}
+	case op.kind == "op" && (op.text == "==" || op.text == "!="):
+	case op.kind == "op" && (op.text == "<" || op.text == "<=" || op.text == ">" || op.text == ">="):
+		if field.kind != kindNumber && field.kind != kindDuration && field.kind != kindSpeed {
+			return nil, p.errorf(op, "operator %s tidak dapat digunakan untuk field [%s], gunakan == != atau in", op.text, t.text)
+		}
+	default:
+		if field.kind == kindBool { // Field boolean saja, contoh: !throttled
+			return &compareNode{field: field, op: "==", values: []filterValue{{boolean: true}}}, nil
+		}
+		return nil, p.errorf(op, "diharapkan operator (== != < <= > >= in) setelah [%s], bukan [%s]", t.text, op.text)
+	}
+	p.next()
+	v, err := p.parseValue(field, t.text)
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{field: field, op: op.text, values: []filterValue{v}}, nil
+}
+
+// Membaca satu nilai literal dan mengonversinya sesuai jenis field
+func (p *filterParser) parseValue(field *filterField, name string) (v filterValue, err error) {
+	t := p.next()
+	invalid := func() (filterValue, error) {
+		return v, p.errorf(t, "nilai [%s] tidak valid untuk field [%s], contoh: %s", t.text, name, exampleValue(field.kind))
+	}
+	switch field.kind {
+	case kindString, kindIP:
+		if t.kind != "string" {
+			return invalid()
+		}
+		v.str = t.text
+		if field.kind == kindIP {
+			if strings.Contains(t.text, "/") {
+				if _, v.cidr, err = net.ParseCIDR(t.text); err != nil {
+					return invalid()
+				}
+			} else if net.ParseIP(t.text) == nil {
+				return invalid()
+			}
+		}
+	case kindBool:
+		if t.kind != "ident" || (t.text != "true" && t.text != "false") {
+			return invalid()
+		}
+		v.boolean = t.text == "true"
+	case kindDuration:
+		if t.kind != "number" {
+			return invalid()
+		}
+		if ms, err := strconv.ParseFloat(t.text, 64); err == nil { // Tanpa satuan berarti milidetik
+			v.num = ms * float64(time.Millisecond)
+		} else if d, err := time.ParseDuration(t.text); err == nil {
+			v.num = float64(d)
+		} else {
+			return invalid()
+		}
+	default:
+		if t.kind != "number" {
+			return invalid()
+		}
+		text, scale := t.text, 1.0
+		if strings.HasSuffix(text, "%") {
+			text, scale = strings.TrimSuffix(text, "%"), 0.01
+		}
+		if v.num, err = strconv.ParseFloat(text, 64); err != nil {
+			return invalid()
+		}
+		v.num *= scale
+		if field.kind == kindSpeed { // MB/s
+			v.num *= 1024 * 1024
+		}
+		if field.single {
+			v.num = float64(float32(v.num))
+		}
+	}
+	return v, nil
+}
+
+func exampleValue(kind fieldKind) string {
+	switch kind {
+	case kindDuration:
+		return "150ms"
+	case kindSpeed:
+		return "5 (MB/s)"
+	case kindString:
+		return `"SIN"`
+	case kindIP:
+		return `"1.1.1.1" atau "104.16.0.0/13"`
+	case kindBool:
+		return "true"
+	}
+	return "0.1"
+}
+
+func filterFieldNames() string {
+	return "ip, port, tls, sent, received, loss, delay (latency), jitter, colo, city, country, region, speed (download), peak, loaded_delay, throttled, aborted, upload"
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mengembalikan [-filter] [-tl] [-tll] [-tlr] ke nilai semula setelah pengujian
+func resetFilter(t *testing.T) {
+	oldExpr, oldMax, oldMin, oldLoss := FilterExpr, InputMaxDelay, InputMinDelay, InputMaxLossRate
+	t.Cleanup(func() {
+		FilterExpr, InputMaxDelay, InputMinDelay, InputMaxLossRate = oldExpr, oldMax, oldMin, oldLoss
+		resultFilter = nil
+	})
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  string
+		msg  string
+	}{
+		{"loss <", "posisi 7", "nilai [akhir ekspresi] tidak valid"},
+		{"foo == 1", "posisi 1", "field [foo] tidak dikenal"},
+		{"speed > abc", "posisi 9", "nilai [abc] tidak valid"},
+		{`colo < "SIN"`, "posisi 6", "operator < tidak dapat digunakan"},
+		{"(loss < 0.1", "posisi 12", "diharapkan )"},
+		{"delay < 1 delay", "posisi 11", "diharapkan && atau ||"},
+		{`colo == "SIN`, "posisi 9", "tidak ditutup"},
+		{"loss # 1", "posisi 6", "karakter [#] tidak dikenal"},
+		{`ip == "1.2.3"`, "posisi 7", "nilai [1.2.3] tidak valid"},
+		{`colo in "SIN"`, "posisi 9", "operator in memerlukan daftar"},
+		{`colo in ["SIN" "HKG"]`, "posisi 16", "diharapkan , atau ]"},
+		{"&& loss < 1", "posisi 1", "diharapkan nama field"},
+		{"delay", "posisi 6", "diharapkan operator"},
+	}
+	for _, tt := range tests {
+		_, err := parseFilter(tt.expr)
+		if err == nil {
+			t.Errorf("parseFilter(%q) = nil error, want error at %s", tt.expr, tt.pos)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.pos+":") || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("parseFilter(%q) error = %q, want %s: %s", tt.expr, err, tt.pos, tt.msg)
+		}
+	}
+}
+
+func TestFilterPrecedence(t *testing.T) {
+	cf := CloudflareIPData{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("104.16.1.1")}, Port: 443, TLS: true, Sended: 4, Received: 4, Delay: 100 * time.Millisecond, Colo: "SIN"}}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"port == 80 || port == 443 && tls", true},
+		{"port == 443 || port == 80 && !tls", true},
+		{"(port == 443 || port == 80) && !tls", false},
+		{`!tls || colo == "SIN"`, true},
+		{`!(tls || colo == "SIN")`, false},
+		{"!port == 443", false},
+		{"!!tls", true},
+		{`port == 80 && tls || colo == "sin"`, true},
+		{`port == 80 && (tls || colo == "sin")`, false},
+		{`delay < 150ms && loss <= 0% && ip == "104.16.0.0/13"`, true},
+		{`colo in ["HKG", "SIN"] && delay >= 100`, true},
+	}
+	for _, tt := range tests {
+		node, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := node.eval(&cf, StageUpload); got != toTri(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFilterStages(t *testing.T) {
+	resetFilter(t)
+	FilterExpr = `speed > 5 || colo == "HKG"`
+	if err := ParseFilter(); err != nil {
+		t.Fatal(err)
+	}
+	cf := CloudflareIPData{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 1, Received: 1}}
+	if !FilterAllows(&cf, StagePing) || !FilterAllows(&cf, StageColo) {
+		t.Errorf("speed and empty colo are not measured yet, IP should pass before the download stage")
+	}
+	cf.Colo = "SIN"
+	if !FilterAllows(&cf, StageColo) {
+		t.Errorf("speed is not measured yet, IP should pass the colo stage")
+	}
+	if FilterAllows(&cf, StageDownload) {
+		t.Errorf("speed 0 and colo SIN should be rejected after the download stage")
+	}
+	cf.DownloadSpeed = 6 * 1024 * 1024
+	if !FilterAllows(&cf, StageDownload) {
+		t.Errorf("speed 6 MB/s should pass after the download stage")
+	}
+}
+
+func TestFilterLossPrecision(t *testing.T) {
+	tests := []struct {
+		sent, received int
+		expr           string
+		want           bool
+	}{
+		{10, 9, "loss <= 0.1", true},
+		{10, 9, "loss <= 10%", true},
+		{10, 9, "loss < 0.1", false},
+		{10, 9, "loss == 0.1", true},
+		{5, 4, "loss <= 0.2", true},
+		{3, 2, "loss <= 0.33", false},
+		{10, 8, "loss <= 0.1", false},
+	}
+	for _, tt := range tests {
+		cf := CloudflareIPData{PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: tt.sent, Received: tt.received}}
+		node, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := node.eval(&cf, StagePing); got != toTri(tt.want) {
+			t.Errorf("%d/%d lost: %s = %v, want %v", tt.sent-tt.received, tt.sent, tt.expr, got, tt.want)
+		}
+	}
+}
+
+// Penyaringan [-tl] [-tll] [-tlr] sebelum [-filter] ditambahkan (FilterDelay lalu FilterLossRate)
+func legacyFilter(s PingDelaySet) (data PingDelaySet) {
+	for _, v := range s {
+		if InputMaxDelay <= maxDelay && InputMinDelay >= minDelay && (InputMaxDelay != maxDelay || InputMinDelay != minDelay) &&
+			(v.Delay > InputMaxDelay || v.Delay < InputMinDelay) {
+			continue
+		}
+		if InputMaxLossRate < maxLossRate && v.getLossRate() > InputMaxLossRate {
+			continue
+		}
+		data = append(data, v)
+	}
+	return
+}
+
+func TestBuiltinFilterMatchesLegacy(t *testing.T) {
+	resetFilter(t)
+	var s PingDelaySet
+	for sent := 1; sent <= 10; sent++ {
+		for received := 0; received <= sent; received++ {
+			delay := time.Duration(sent*37+received*11) * time.Millisecond
+			s = append(s, CloudflareIPData{PingData: &PingData{IP: &net.IPAddr{IP: net.IPv4(1, 1, byte(sent), byte(received))}, Sended: sent, Received: received, Delay: delay}})
+		}
+	}
+	tests := []struct {
+		maxDelay, minDelay time.Duration
+		maxLoss            float32
+	}{
+		{maxDelay, minDelay, maxLossRate},
+		{200 * time.Millisecond, minDelay, maxLossRate},
+		{maxDelay, 100 * time.Millisecond, maxLossRate},
+		{300 * time.Millisecond, 150 * time.Millisecond, maxLossRate},
+		{10000 * time.Millisecond, 100 * time.Millisecond, maxLossRate}, // Di luar batasan default, tidak difilter
+		{maxDelay, minDelay, 0},
+		{maxDelay, minDelay, 0.1},
+		{maxDelay, minDelay, 0.2},
+		{maxDelay, minDelay, 0.25},
+		{maxDelay, minDelay, 0.3},
+		{maxDelay, minDelay, 0.5},
+		{maxDelay, minDelay, 0.7},
+		{250 * time.Millisecond, 50 * time.Millisecond, 0.1},
+	}
+	for _, tt := range tests {
+		FilterExpr, InputMaxDelay, InputMinDelay, InputMaxLossRate = "", tt.maxDelay, tt.minDelay, tt.maxLoss
+		if err := ParseFilter(); err != nil {
+			t.Fatal(err)
+		}
+		want, got := legacyFilter(s), s.Filter(StagePing)
+		if len(got) != len(want) {
+			t.Errorf("-tl %v -tll %v -tlr %v: %d IP, want %d", tt.maxDelay, tt.minDelay, tt.maxLoss, len(got), len(want))
+			continue
+		}
+		for i := range want {
+			if got[i].IP.String() != want[i].IP.String() {
+				t.Errorf("-tl %v -tll %v -tlr %v: [%d] = %s, want %s", tt.maxDelay, tt.minDelay, tt.maxLoss, i, got[i].IP, want[i].IP)
+				break
+			}
+		}
+	}
+}
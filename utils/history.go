@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// HistoryFile adalah file riwayat tempat hasil setiap pengujian ditambahkan, string kosong berarti tidak dicatat (-history)
+	HistoryFile string
+	// Network adalah nama jaringan yang dicatat ke riwayat, contoh: rumah, kantor, seluler (-network)
+	Network string
+)
+
+// Identitas jaringan tempat pengujian dijalankan
+type NetworkInfo struct {
+	Name     string `json:"name,omitempty"`     // Dari [-network]
+	Hostname string `json:"hostname,omitempty"` // Nama perangkat
+	LocalIP  string `json:"local_ip,omitempty"` // Alamat lokal rute default
+}
+
+// Satu pengujian dalam file riwayat, file riwayat berisi satu objek JSON per baris dan hanya ditambahkan
+type HistoryRun struct {
+	SchemaVersion int               `json:"schema_version"`
+	Run           RunInfo           `json:"run"`
+	Network       NetworkInfo       `json:"network"`
+	Config        map[string]string `json:"config,omitempty"` // Parameter yang ditentukan pada baris perintah
+	Results       []jsonResult      `json:"results"`
+}
+
+// Mendeteksi identitas jaringan saat ini, alamat lokal diambil dari rute UDP tanpa mengirim paket
+func detectNetwork() NetworkInfo {
+	info := NetworkInfo{Name: Network}
+	info.Hostname, _ = os.Hostname()
+	for _, address := range []string{"1.1.1.1:53", "[2606:4700:4700::1111]:53"} {
+		conn, err := net.Dial("udp", address)
+		if err != nil {
+			continue
+		}
+		info.LocalIP = conn.LocalAddr().(*net.UDPAddr).IP.String()
+		conn.Close()
+		break
+	}
+	return info
+}
+
+// Menambahkan hasil pengujian ini ke file riwayat [-history], config adalah parameter yang ditentukan pada baris perintah
+func AppendHistory(data []CloudflareIPData, config map[string]string) error {
+	if HistoryFile == "" || len(data) == 0 {
+		return nil
+	}
+	if Run.FinishedAt.IsZero() {
+		Run.FinishedAt = time.Now()
+	}
+	entry := HistoryRun{SchemaVersion: SchemaVersion, Run: Run, Network: detectNetwork(), Config: config, Results: make([]jsonResult, 0, len(data))}
+	for _, v := range data {
+		entry.Results = append(entry.Results, newJSONResult(v))
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("gagal menulis file riwayat [%s]: %v", HistoryFile, err)
+	}
+	fp, err := os.OpenFile(HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("gagal membuka file riwayat [%s]: %v", HistoryFile, err)
+	}
+	defer fp.Close()
+	if _, err = fp.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("gagal menulis file riwayat [%s]: %v", HistoryFile, err)
+	}
+	return nil
+}
+
+// ReadHistory membaca semua pengujian dari file riwayat, berurutan dari yang terlama.
+// Jika network tidak kosong, hanya pengujian dengan nama jaringan tersebut yang diambil
+func ReadHistory(file, network string) ([]HistoryRun, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuka file riwayat [%s]: %v", file, err)
+	}
+	defer fp.Close()
+	var runs []HistoryRun
+	scanner := bufio.NewScanner(fp)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024) // Satu baris berisi semua hasil satu pengujian
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var run HistoryRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("file riwayat [%s] baris %d tidak valid: %v", file, line, err)
+		}
+		if err := checkSchemaVersion(run.SchemaVersion); err != nil {
+			return nil, fmt.Errorf("file riwayat [%s]: %v", file, err)
+		}
+		if network != "" && !strings.EqualFold(run.Network.Name, network) {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("gagal membaca file riwayat [%s]: %v", file, err)
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Run.StartedAt.Before(runs[j].Run.StartedAt) })
+	return runs, nil
+}
+
+// LastRuns mengambil n pengujian terakhir, n <= 0 berarti semua
+func LastRuns(runs []HistoryRun, n int) []HistoryRun {
+	if n > 0 && len(runs) > n {
+		return runs[len(runs)-n:]
+	}
+	return runs
+}
+
+// Satu titik riwayat sebuah IP atau colo
+type HistoryPoint struct {
+	At          time.Time `json:"at"`
+	Network     string    `json:"network,omitempty"`
+	Present     bool      `json:"present"`         // Apakah IP/colo ada dalam hasil pengujian tersebut
+	Rank        int       `json:"rank,omitempty"`  // Peringkat IP (riwayat IP) atau peringkat IP terbaik (riwayat colo)
+	Count       int       `json:"count,omitempty"` // Jumlah IP dalam colo (riwayat colo)
+	Colo        string    `json:"colo,omitempty"`  // Colo IP (riwayat IP)
+	LatencyNs   int64     `json:"latency_ns"`      // Latensi IP, atau latensi terendah dalam colo
+	DownloadBps float64   `json:"download_bps"`    // Kecepatan unduh IP, atau kecepatan unduh tertinggi dalam colo
+	Loss        float64   `json:"loss"`            // Kehilangan paket IP, atau rata-rata dalam colo
+}
+
+// IPHistory mengembalikan latensi dan kecepatan unduh sebuah IP di setiap pengujian
+func IPHistory(runs []HistoryRun, ip string) []HistoryPoint {
+	points := make([]HistoryPoint, 0, len(runs))
+	for _, run := range runs {
+		p := HistoryPoint{At: run.Run.StartedAt, Network: run.Network.Name}
+		for i, r := range run.Results {
+			if r.IP == ip {
+				p.Present, p.Rank, p.Colo = true, i+1, r.Colo
+				p.LatencyNs, p.DownloadBps, p.Loss = r.LatencyNs, r.DownloadBps, r.Loss
+				break
+			}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// ColoHistory mengembalikan latensi terendah dan kecepatan unduh tertinggi IP dalam sebuah colo di setiap pengujian
+func ColoHistory(runs []HistoryRun, colo string) []HistoryPoint {
+	points := make([]HistoryPoint, 0, len(runs))
+	for _, run := range runs {
+		p := HistoryPoint{At: run.Run.StartedAt, Network: run.Network.Name}
+		for i, r := range run.Results {
+			if !strings.EqualFold(r.Colo, colo) {
+				continue
+			}
+			if !p.Present {
+				p.Present, p.Rank = true, i+1
+			}
+			p.Count++
+			p.Loss += r.Loss
+			if r.LatencyNs > 0 && (p.LatencyNs == 0 || r.LatencyNs < p.LatencyNs) {
+				p.LatencyNs = r.LatencyNs
+			}
+			if r.DownloadBps > p.DownloadBps {
+				p.DownloadBps = r.DownloadBps
+			}
+		}
+		if p.Count > 0 {
+			p.Loss /= float64(p.Count)
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// IP yang konsisten baik di beberapa pengujian terakhir
+type ConsistentIP struct {
+	IP             string   `json:"ip"`
+	Runs           int      `json:"runs"`
+	AvgLatencyNs   int64    `json:"avg_latency_ns"`
+	MaxLatencyNs   int64    `json:"max_latency_ns"`
+	AvgDownloadBps float64  `json:"avg_download_bps"`
+	MinDownloadBps float64  `json:"min_download_bps"`
+	AvgRank        float64  `json:"avg_rank"`
+	Colos          []string `json:"colos"`
+}
+
+// ConsistentIPs mengembalikan IP yang ada dalam hasil setiap pengujian yang diberikan dan di setiap pengujian
+// latensinya tidak melebihi maxLatency serta kecepatan unduhnya tidak di bawah minSpeed (0 berarti tidak diperiksa),
+// diurutkan berdasarkan latensi rata-rata
+func ConsistentIPs(runs []HistoryRun, maxLatency time.Duration, minSpeed float64) []ConsistentIP {
+	stats := make(map[string]*ConsistentIP)
+	for _, run := range runs {
+		seen := make(map[string]bool, len(run.Results))
+		for i, r := range run.Results {
+			if seen[r.IP] {
+				continue
+			}
+			seen[r.IP] = true
+			if maxLatency > 0 && time.Duration(r.LatencyNs) > maxLatency {
+				continue
+			}
+			if minSpeed > 0 && r.DownloadBps < minSpeed {
+				continue
+			}
+			s, ok := stats[r.IP]
+			if !ok {
+				s = &ConsistentIP{IP: r.IP, MinDownloadBps: r.DownloadBps, Colos: []string{}}
+				stats[r.IP] = s
+			}
+			s.Runs++
+			s.AvgLatencyNs += r.LatencyNs
+			s.AvgDownloadBps += r.DownloadBps
+			s.AvgRank += float64(i + 1)
+			if r.LatencyNs > s.MaxLatencyNs {
+				s.MaxLatencyNs = r.LatencyNs
+			}
+			if r.DownloadBps < s.MinDownloadBps {
+				s.MinDownloadBps = r.DownloadBps
+			}
+			if r.Colo != "" && !containsString(s.Colos, r.Colo) {
+				s.Colos = append(s.Colos, r.Colo)
+			}
+		}
+	}
+	good := []ConsistentIP{}
+	for _, s := range stats {
+		if s.Runs < len(runs) {
+			continue
+		}
+		s.AvgLatencyNs /= int64(s.Runs)
+		s.AvgDownloadBps /= float64(s.Runs)
+		s.AvgRank /= float64(s.Runs)
+		good = append(good, *s)
+	}
+	sort.Slice(good, func(i, j int) bool {
+		if good[i].AvgLatencyNs != good[j].AvgLatencyNs {
+			return good[i].AvgLatencyNs < good[j].AvgLatencyNs
+		}
+		return good[i].IP < good[j].IP
+	})
+	return good
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Menampilkan daftar pengujian dalam riwayat
+func PrintHistoryRuns(runs []HistoryRun) {
+	format := "%-22s%-14s%-8s%-8s%-18s%-10s%-28s\n"
+	fmt.Printf(format, "Waktu", "Jaringan", "Mode", "Port", "Alamat Lokal", "Jumlah IP", "IP Terbaik")
+	for _, run := range runs {
+		best := ""
+		if len(run.Results) > 0 {
+			r := run.Results[0]
+			best = fmt.Sprintf("%s (%s ms)", r.IP, formatMillis(time.Duration(r.LatencyNs)))
+		}
+		fmt.Printf(format, run.Run.StartedAt.Local().Format("2006-01-02 15:04:05"), orDash(run.Network.Name), run.Run.Mode,
+			fmt.Sprint(run.Run.Port), orDash(run.Network.LocalIP), fmt.Sprint(len(run.Results)), best)
+	}
+}
+
+// Menampilkan riwayat IP atau colo
+func PrintHistoryPoints(points []HistoryPoint, colo bool) {
+	format := "%-22s%-14s%-12s%-10s%-18s%-24s%-12s\n"
+	column := "Colo"
+	if colo {
+		column = "Jumlah IP"
+	}
+	fmt.Printf(format, "Waktu", "Jaringan", "Peringkat", "Latensi", "Kehilangan Paket", "Kecepatan Unduh (MB/s)", column)
+	for _, p := range points {
+		at := p.At.Local().Format("2006-01-02 15:04:05")
+		if !p.Present {
+			fmt.Printf(format, at, orDash(p.Network), "-", "-", "-", "-", "tidak ada")
+			continue
+		}
+		last := p.Colo
+		if colo {
+			last = fmt.Sprint(p.Count)
+		}
+		fmt.Printf(format, at, orDash(p.Network), fmt.Sprint(p.Rank), formatMillis(time.Duration(p.LatencyNs)),
+			fmt.Sprintf("%.2f", p.Loss), formatMBps(p.DownloadBps), last)
+	}
+}
+
+// Menampilkan IP yang konsisten baik
+func PrintConsistentIPs(ips []ConsistentIP) {
+	format := "%-42s%-14s%-18s%-22s%-22s%-12s%-12s\n"
+	fmt.Printf(format, "Alamat IP", "Rata Latensi", "Latensi Tertinggi", "Rata Unduh (MB/s)", "Unduh Terendah (MB/s)", "Rata Posisi", "Colo")
+	for _, v := range ips {
+		fmt.Printf(format, v.IP, formatMillis(time.Duration(v.AvgLatencyNs)), formatMillis(time.Duration(v.MaxLatencyNs)),
+			formatMBps(v.AvgDownloadBps), formatMBps(v.MinDownloadBps), fmt.Sprintf("%.1f", v.AvgRank), strings.Join(v.Colos, ","))
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
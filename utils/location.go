@@ -0,0 +1,165 @@
+package utils
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLocationFile = "locations.json"
+	defaultLocationURL  = "https://speed.cloudflare.com/locations"
+	minLocations        = 50 // Jumlah lokasi minimum agar data dianggap valid (data resmi berisi ratusan lokasi)
+)
+
+var (
+	// LocationFile adalah file data lokasi dari https://speed.cloudflare.com/locations
+	LocationFile = defaultLocationFile
+	LocationURL  = defaultLocationURL
+
+	// Salinan data lokasi yang disematkan ke dalam program, digunakan jika file tidak ada atau rusak
+	//go:embed locations.json
+	embeddedLocations []byte
+
+	iataRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+	cca2Regexp = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+type Location struct {
+	Iata   string  `json:"iata"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Cca2   string  `json:"cca2"`
+	Region string  `json:"region"`
+	City   string  `json:"city"`
+}
+
+// Membaca data lokasi dari file, jika file tidak ada atau rusak maka gunakan data yang disematkan (embedded = true)
+func LoadLocations(file string) (locations []Location, embedded bool) {
+	locations, err := ReadLocations(file)
+	if err == nil {
+		return locations, false
+	}
+	locations, _ = ParseLocations(embeddedLocations)
+	return locations, true
+}
+
+// Membaca dan memvalidasi data lokasi dari file
+func ReadLocations(file string) ([]Location, error) {
+	if file == "" {
+		file = defaultLocationFile
+	}
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLocations(body)
+}
+
+// Mem-parse dan memvalidasi data lokasi
+func ParseLocations(body []byte) ([]Location, error) {
+	var locations []Location
+	if err := json.Unmarshal(body, &locations); err != nil {
+		return nil, fmt.Errorf("format JSON tidak valid: %v", err)
+	}
+	if len(locations) < minLocations {
+		return nil, fmt.Errorf("jumlah lokasi terlalu sedikit (%d), data kemungkinan tidak lengkap", len(locations))
+	}
+	seen := make(map[string]bool, len(locations))
+	for _, loc := range locations {
+		if !iataRegexp.MatchString(loc.Iata) {
+			return nil, fmt.Errorf("kode tiga huruf bandara [%s] tidak valid", loc.Iata)
+		}
+		if !cca2Regexp.MatchString(loc.Cca2) {
+			return nil, fmt.Errorf("kode negara [%s] pada lokasi [%s] tidak valid", loc.Cca2, loc.Iata)
+		}
+		if loc.Region == "" {
+			return nil, fmt.Errorf("lokasi [%s] tidak memiliki wilayah", loc.Iata)
+		}
+		if seen[loc.Iata] {
+			return nil, fmt.Errorf("lokasi [%s] duplikat", loc.Iata)
+		}
+		seen[loc.Iata] = true
+	}
+	return locations, nil
+}
+
+// Mengunduh data lokasi terbaru, memvalidasi, lalu menulis ke file; mengembalikan colo yang ditambahkan dan dihapus dibandingkan data sebelumnya
+func UpdateLocations(file string) (added, removed []string, err error) {
+	if file == "" {
+		file = defaultLocationFile
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(LocationURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tidak dapat mengambil data lokasi dari %s: %v", LocationURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, nil, fmt.Errorf("tidak dapat mengambil data lokasi dari %s: kode status %d", LocationURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("tidak dapat membaca body respons: %v", err)
+	}
+	locations, err := ParseLocations(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("data lokasi dari %s tidak valid: %v", LocationURL, err)
+	}
+
+	old, _ := LoadLocations(file) // Data sebelumnya (file atau data yang disematkan)
+	added, removed = diffLocations(old, locations)
+
+	// Tulis ke file sementara lalu ganti nama, agar file lama tidak rusak jika penulisan gagal
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return nil, nil, fmt.Errorf("tidak dapat membuat file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(body); err != nil {
+		tmp.Close()
+		return nil, nil, fmt.Errorf("tidak dapat menulis ke file: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, nil, fmt.Errorf("tidak dapat menulis ke file: %v", err)
+	}
+	if err = os.Rename(tmp.Name(), file); err != nil {
+		return nil, nil, fmt.Errorf("tidak dapat mengganti file [%s]: %v", file, err)
+	}
+	return added, removed, nil
+}
+
+// Membandingkan dua data lokasi, mengembalikan colo yang ditambahkan dan dihapus
+func diffLocations(old, new []Location) (added, removed []string) {
+	oldMap, newMap := LocationMap(old), LocationMap(new)
+	for iata := range newMap {
+		if _, ok := oldMap[iata]; !ok {
+			added = append(added, iata)
+		}
+	}
+	for iata := range oldMap {
+		if _, ok := newMap[iata]; !ok {
+			removed = append(removed, iata)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return
+}
+
+// Membuat peta kode tiga huruf bandara -> lokasi
+func LocationMap(locations []Location) map[string]Location {
+	m := make(map[string]Location, len(locations))
+	for _, loc := range locations {
+		m[strings.ToUpper(loc.Iata)] = loc
+	}
+	return m
+}
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SchemaVersion adalah versi skema file hasil JSON/NDJSON, dinaikkan jika ada perubahan yang tidak kompatibel
+const SchemaVersion = 1
+
+const defaultFormat = "csv"
+
+var (
+	// Outputs adalah daftar file hasil (-o dapat digunakan berkali-kali), format setiap file ditentukan dari ekstensinya
+	// (.csv, .json, .ndjson/.jsonl) atau dari [-format] jika ekstensi tidak dikenali, string kosong berarti tidak menulis ke file
+	Outputs []string
+	// Format adalah format file hasil default: csv, json atau ndjson (-format)
+	Format = defaultFormat
+	// Run adalah metadata pengujian yang ditulis ke file hasil JSON/NDJSON
+	Run RunInfo
+)
+
+// Metadata satu kali pengujian
+type RunInfo struct {
+	Tool       string    `json:"tool"`
+	Version    string    `json:"version"`
+	Mode       string    `json:"mode"` // tcp atau http
+	Port       int       `json:"port"`
+	Provider   string    `json:"provider"`
+	URLs       []string  `json:"urls,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Memvalidasi [-format] dan menentukan file hasil default, dipanggil saat program dimulai
+func ParseOutputs() error {
+	Format = strings.ToLower(strings.TrimSpace(Format))
+	switch Format {
+	case "csv", "json", "ndjson":
+	default:
+		return fmt.Errorf("format [-format %s] tidak valid, pilihan: csv, json, ndjson", Format)
+	}
+	if len(Outputs) == 0 { // Tanpa [-o], gunakan result.<format>
+		Outputs = []string{"result." + Format}
+	}
+	return nil
+}
+
+// File hasil yang akan ditulis
+func outputFiles() (files []string) {
+	for _, file := range Outputs {
+		if file = strings.TrimSpace(file); file != "" {
+			files = append(files, file)
+		}
+	}
+	return
+}
+
+// Format file hasil berdasarkan ekstensi, jika tidak dikenali gunakan [-format]
+func outputFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return "csv"
+	case ".json":
+		return "json"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	}
+	return Format
+}
+
+// Menulis hasil ke semua file [-o] sesuai formatnya
+func ExportResults(data []CloudflareIPData) {
+	if len(data) == 0 {
+		return
+	}
+	if Run.FinishedAt.IsZero() {
+		Run.FinishedAt = time.Now()
+	}
+	for _, file := range outputFiles() {
+		switch outputFormat(file) {
+		case "json":
+			exportJSON(file, data)
+		case "ndjson":
+			exportNDJSON(file, data)
+		default:
+			exportCsv(file, data)
+		}
+	}
+}
+
+// Satu hasil dalam file JSON/NDJSON, semua nilai mentah tanpa pembulatan:
+// latensi dan durasi dalam nanodetik, kecepatan dalam byte/detik, kehilangan paket sebagai rasio 0~1
+type jsonResult struct {
+	IP              string        `json:"ip"`
+	Port            int           `json:"port"`
+	TLS             bool          `json:"tls"`
+	Sent            int           `json:"sent"` // 0 berarti pengujian latensi tidak dijalankan (pencari proxy), received dan loss juga 0
+	Received        int           `json:"received"`
+	Loss            float64       `json:"loss"`
+	LatencyNs       int64         `json:"latency_ns"`
+	JitterNs        int64         `json:"jitter_ns"`
+	LoadedLatencyNs int64         `json:"loaded_latency_ns"`
+	IdleLatencyNs   int64         `json:"idle_latency_ns"`
+	Colo            string        `json:"colo"`
+	City            string        `json:"city"`
+	Country         string        `json:"country"`
+	Region          string        `json:"region"`
+	DownloadBps     float64       `json:"download_bps"`
+	DownloadAvgBps  float64       `json:"download_avg_bps"`
+	DownloadPeakBps float64       `json:"download_peak_bps"`
+	DownloadBytes   int64         `json:"download_bytes"`
+	DownloadNs      int64         `json:"download_ns"`
+	ConnBps         []float64     `json:"conn_bps,omitempty"`
+	UploadBps       float64       `json:"upload_bps"`
+	DownloadURL     string        `json:"download_url,omitempty"`
+	DownloadError   string        `json:"download_error,omitempty"`
+	VerifyError     string        `json:"verify_error,omitempty"`
+	Aborted         bool          `json:"aborted"`
+	Throttled       bool          `json:"throttled"`
+	Score           float64       `json:"score"`
+	Series          []jsonPoint   `json:"series,omitempty"`
+	Previous        *jsonPrevious `json:"previous,omitempty"`
+}
+
+// Hasil sebelumnya dan selisihnya (baru - lama) saat pengujian ulang (-from-result)
+type jsonPrevious struct {
+	LatencyNs        int64   `json:"latency_ns"`
+	DownloadBps      float64 `json:"download_bps"`
+	UploadBps        float64 `json:"upload_bps"`
+	Colo             string  `json:"colo"`
+	LatencyDeltaNs   int64   `json:"latency_delta_ns"`
+	DownloadDeltaBps float64 `json:"download_delta_bps"`
+}
+
+// Satu titik deret waktu: byte yang diterima dalam potongan waktu yang berakhir pada at_ns
+type jsonPoint struct {
+	AtNs  int64 `json:"at_ns"`
+	Bytes int64 `json:"bytes"`
+}
+
+func newJSONResult(cf CloudflareIPData) jsonResult {
+	r := jsonResult{
+		IP:              cf.IP.String(),
+		Port:            cf.Port,
+		TLS:             cf.TLS,
+		Sent:            cf.Sended,
+		Received:        cf.Received,
+		Loss:            float64(cf.getLossRate()),
+		LatencyNs:       cf.Delay.Nanoseconds(),
+		JitterNs:        cf.Jitter.Nanoseconds(),
+		LoadedLatencyNs: cf.LoadedDelay.Nanoseconds(),
+		IdleLatencyNs:   cf.IdleDelay.Nanoseconds(),
+		Colo:            cf.Colo,
+		City:            cf.City,
+		Country:         cf.Country,
+		Region:          cf.Region,
+		DownloadBps:     cf.DownloadSpeed,
+		DownloadAvgBps:  cf.Download.Average,
+		DownloadPeakBps: cf.Download.Peak,
+		DownloadBytes:   cf.Download.Bytes,
+		DownloadNs:      cf.Download.Elapsed.Nanoseconds(),
+		ConnBps:         cf.ConnSpeeds,
+		UploadBps:       cf.UploadSpeed,
+		DownloadURL:     cf.DownloadURL,
+		DownloadError:   cf.DownloadError,
+		VerifyError:     cf.VerifyError,
+		Aborted:         cf.Aborted,
+		Throttled:       cf.Download.Throttled,
+		Score:           cf.Score,
+	}
+	for _, p := range cf.Download.Series {
+		r.Series = append(r.Series, jsonPoint{AtNs: p.At.Nanoseconds(), Bytes: p.Bytes})
+	}
+	if prev := cf.Previous; prev != nil {
+		r.Previous = &jsonPrevious{
+			LatencyNs:        prev.Delay.Nanoseconds(),
+			DownloadBps:      prev.DownloadSpeed,
+			UploadBps:        prev.UploadSpeed,
+			Colo:             prev.Colo,
+			LatencyDeltaNs:   (cf.Delay - prev.Delay).Nanoseconds(),
+			DownloadDeltaBps: cf.DownloadSpeed - prev.DownloadSpeed,
+		}
+	}
+	return r
+}
+
+// Mengubah hasil JSON kembali menjadi data hasil
+func (r jsonResult) toData() (CloudflareIPData, error) {
+	ip, err := parseIPAddr(r.IP)
+	if err != nil {
+		return CloudflareIPData{}, err
+	}
+	cf := CloudflareIPData{
+		PingData: &PingData{
+			IP:       ip,
+			Port:     r.Port,
+			TLS:      r.TLS,
+			Sended:   r.Sent,
+			Received: r.Received,
+			Delay:    time.Duration(r.LatencyNs),
+			Jitter:   time.Duration(r.JitterNs),
+			Colo:     r.Colo,
+			City:     r.City,
+			Country:  r.Country,
+			Region:   r.Region,
+		},
+		lossRate:      float32(r.Loss),
+		DownloadSpeed: r.DownloadBps,
+		Download: SpeedStats{
+			Average:   r.DownloadAvgBps,
+			Sustained: r.DownloadBps,
+			Peak:      r.DownloadPeakBps,
+			Bytes:     r.DownloadBytes,
+			Elapsed:   time.Duration(r.DownloadNs),
+			Throttled: r.Throttled,
+		},
+		UploadSpeed:   r.UploadBps,
+		ConnSpeeds:    r.ConnBps,
+		VerifyError:   r.VerifyError,
+		Aborted:       r.Aborted,
+		DownloadURL:   r.DownloadURL,
+		DownloadError: r.DownloadError,
+		LoadedDelay:   time.Duration(r.LoadedLatencyNs),
+		IdleDelay:     time.Duration(r.IdleLatencyNs),
+		Score:         r.Score,
+	}
+	for _, p := range r.Series {
+		cf.Download.Series = append(cf.Download.Series, SeriesPoint{At: time.Duration(p.AtNs), Bytes: p.Bytes})
+	}
+	if prev := r.Previous; prev != nil {
+		cf.Previous = &CloudflareIPData{
+			PingData:      &PingData{IP: ip, Port: r.Port, Delay: time.Duration(prev.LatencyNs), Colo: prev.Colo},
+			DownloadSpeed: prev.DownloadBps,
+			UploadSpeed:   prev.UploadBps,
+		}
+	}
+	return cf, nil
+}
+
+// Satu dokumen JSON: metadata dan semua hasil
+func exportJSON(file string, data []CloudflareIPData) {
+	doc := struct {
+		SchemaVersion int          `json:"schema_version"`
+		Run           RunInfo      `json:"run"`
+		Results       []jsonResult `json:"results"`
+	}{SchemaVersion: SchemaVersion, Run: Run, Results: make([]jsonResult, 0, len(data))}
+	for _, v := range data {
+		doc.Results = append(doc.Results, newJSONResult(v))
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Fatalf("Gagal menulis file [%s]: %v", file, err)
+		return
+	}
+	if err = os.WriteFile(file, append(b, '\n'), 0644); err != nil {
+		log.Fatalf("Gagal membuat file [%s]: %v", file, err)
+	}
+}
+
+// Satu objek JSON per baris: baris pertama metadata ("type": "run"), setiap baris berikutnya satu hasil ("type": "result")
+func exportNDJSON(file string, data []CloudflareIPData) {
+	fp, err := os.Create(file)
+	if err != nil {
+		log.Fatalf("Gagal membuat file [%s]: %v", file, err)
+		return
+	}
+	defer fp.Close()
+	enc := json.NewEncoder(fp)
+	_ = enc.Encode(struct {
+		Type          string `json:"type"`
+		SchemaVersion int    `json:"schema_version"`
+		RunInfo
+	}{"run", SchemaVersion, Run})
+	for _, v := range data {
+		_ = enc.Encode(struct {
+			Type          string `json:"type"`
+			SchemaVersion int    `json:"schema_version"`
+			jsonResult
+		}{"result", SchemaVersion, newJSONResult(v)})
+	}
+}
@@ -0,0 +1,327 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Satu kolom file hasil CSV: nama header, cara menulis nilai dan cara membacanya kembali
+type csvColumn struct {
+	name  string
+	write func(cf *CloudflareIPData) string
+	read  func(cf *CloudflareIPData, v string) error
+}
+
+// Skema file hasil CSV yang digunakan bersama oleh pemindai (cfst.go) dan pencari proxy (linux.go),
+// urutan kolom tetap agar skrip yang membaca berdasarkan posisi tidak rusak, kolom baru selalu ditambahkan di akhir
+var csvColumns = []csvColumn{
+	{"Alamat IP", func(cf *CloudflareIPData) string { return cf.IP.String() }, func(cf *CloudflareIPData, v string) (err error) {
+		cf.IP, err = parseIPAddr(v)
+		return
+	}},
+	// Kolom pengujian latensi kosong jika pengujian tersebut tidak dijalankan (pencari proxy tidak mengukur kehilangan paket)
+	{"Terkirim", func(cf *CloudflareIPData) string { return pingCount(cf, cf.Sended) }, readInt(func(cf *CloudflareIPData) *int { return &cf.Sended })},
+	{"Diterima", func(cf *CloudflareIPData) string { return pingCount(cf, cf.Received) }, readInt(func(cf *CloudflareIPData) *int { return &cf.Received })},
+	{"Tingkat Kehilangan Paket", func(cf *CloudflareIPData) string {
+		if cf.Sended == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(float64(cf.getLossRate()), 'f', 2, 32)
+	}, func(cf *CloudflareIPData, v string) error {
+		rate, err := strconv.ParseFloat(v, 32)
+		cf.lossRate = float32(rate)
+		return err
+	}},
+	{"Rata-rata Latensi", func(cf *CloudflareIPData) string { return formatMillis(cf.Delay) }, readMillis(func(cf *CloudflareIPData) *time.Duration { return &cf.Delay })},
+	{"Kecepatan Unduh (MB/s)", func(cf *CloudflareIPData) string { return formatMBps(cf.DownloadSpeed) }, readMBps(func(cf *CloudflareIPData) *float64 { return &cf.DownloadSpeed })},
+	{"Kecepatan Unggah (MB/s)", func(cf *CloudflareIPData) string { return formatMBps(cf.UploadSpeed) }, readMBps(func(cf *CloudflareIPData) *float64 { return &cf.UploadSpeed })},
+	{"Colo", func(cf *CloudflareIPData) string { return cf.Colo }, readString(func(cf *CloudflareIPData) *string { return &cf.Colo })},
+	{"Kota", func(cf *CloudflareIPData) string { return cf.City }, readString(func(cf *CloudflareIPData) *string { return &cf.City })},
+	{"Negara", func(cf *CloudflareIPData) string { return cf.Country }, readString(func(cf *CloudflareIPData) *string { return &cf.Country })},
+	{"Wilayah", func(cf *CloudflareIPData) string { return cf.Region }, readString(func(cf *CloudflareIPData) *string { return &cf.Region })},
+	{"Kecepatan per Koneksi (MB/s)", (*CloudflareIPData).connSpeedsString, func(cf *CloudflareIPData, v string) error {
+		cf.ConnSpeeds = nil
+		for _, s := range strings.Split(v, "/") {
+			speed, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return err
+			}
+			cf.ConnSpeeds = append(cf.ConnSpeeds, speed*1024*1024)
+		}
+		return nil
+	}},
+	{"Rata-rata Unduh (MB/s)", func(cf *CloudflareIPData) string { return formatMBps(cf.Download.Average) }, readMBps(func(cf *CloudflareIPData) *float64 { return &cf.Download.Average })},
+	{"Puncak Unduh (MB/s)", func(cf *CloudflareIPData) string { return formatMBps(cf.Download.Peak) }, readMBps(func(cf *CloudflareIPData) *float64 { return &cf.Download.Peak })},
+	{"Unduh Stabil (MB/s)", func(cf *CloudflareIPData) string { return formatMBps(cf.Download.Sustained) }, readMBps(func(cf *CloudflareIPData) *float64 { return &cf.Download.Sustained })},
+	{"Total Unduhan (byte)", func(cf *CloudflareIPData) string { return strconv.FormatInt(cf.Download.Bytes, 10) }, func(cf *CloudflareIPData, v string) (err error) {
+		cf.Download.Bytes, err = strconv.ParseInt(v, 10, 64)
+		return
+	}},
+	{"Durasi Unduh (detik)", func(cf *CloudflareIPData) string {
+		return strconv.FormatFloat(cf.Download.Elapsed.Seconds(), 'f', 2, 32)
+	}, func(cf *CloudflareIPData, v string) error {
+		seconds, err := strconv.ParseFloat(v, 64)
+		cf.Download.Elapsed = time.Duration(seconds * float64(time.Second))
+		return err
+	}},
+	{"Gagal Verifikasi", func(cf *CloudflareIPData) string { return cf.VerifyError }, readString(func(cf *CloudflareIPData) *string { return &cf.VerifyError })},
+	{"Dihentikan Awal", func(cf *CloudflareIPData) string { return strconv.FormatBool(cf.Aborted) }, readBool(func(cf *CloudflareIPData) *bool { return &cf.Aborted })},
+	{"Alamat Unduh", func(cf *CloudflareIPData) string { return cf.DownloadURL }, readString(func(cf *CloudflareIPData) *string { return &cf.DownloadURL })},
+	{"Alasan Gagal", func(cf *CloudflareIPData) string { return cf.DownloadError }, readString(func(cf *CloudflareIPData) *string { return &cf.DownloadError })},
+	{"Latensi Saat Beban", func(cf *CloudflareIPData) string { return formatMillis(cf.LoadedDelay) }, readMillis(func(cf *CloudflareIPData) *time.Duration { return &cf.LoadedDelay })},
+	{"Kenaikan Latensi Beban", func(cf *CloudflareIPData) string { return formatMillis(cf.LoadedDelta()) }, nil}, // Dihitung dari latensi saat beban
+	{"Dibatasi", func(cf *CloudflareIPData) string { return strconv.FormatBool(cf.Download.Throttled) }, readBool(func(cf *CloudflareIPData) *bool { return &cf.Download.Throttled })},
+	{"Port", func(cf *CloudflareIPData) string { return strconv.Itoa(cf.Port) }, readInt(func(cf *CloudflareIPData) *int { return &cf.Port })},
+	{"TLS", func(cf *CloudflareIPData) string { return strconv.FormatBool(cf.TLS) }, readBool(func(cf *CloudflareIPData) *bool { return &cf.TLS })},
+	// Kolom pengujian ulang (-from-result), kosong jika IP tidak ada dalam hasil sebelumnya
+	{"Latensi Sebelumnya", func(cf *CloudflareIPData) string {
+		if cf.Previous == nil {
+			return ""
+		}
+		return formatMillis(cf.Previous.Delay)
+	}, readMillis(func(cf *CloudflareIPData) *time.Duration { return &cf.previous().Delay })},
+	{"Selisih Latensi", func(cf *CloudflareIPData) string {
+		if cf.Previous == nil {
+			return ""
+		}
+		return formatMillis(cf.Delay - cf.Previous.Delay)
+	}, nil},
+	{"Kecepatan Unduh Sebelumnya (MB/s)", func(cf *CloudflareIPData) string {
+		if cf.Previous == nil {
+			return ""
+		}
+		return formatMBps(cf.Previous.DownloadSpeed)
+	}, readMBps(func(cf *CloudflareIPData) *float64 { return &cf.previous().DownloadSpeed })},
+	{"Selisih Kecepatan Unduh (MB/s)", func(cf *CloudflareIPData) string {
+		if cf.Previous == nil {
+			return ""
+		}
+		return formatMBps(cf.DownloadSpeed - cf.Previous.DownloadSpeed)
+	}, nil},
+	{"Jitter", func(cf *CloudflareIPData) string { return formatMillis(cf.Jitter) }, readMillis(func(cf *CloudflareIPData) *time.Duration { return &cf.Jitter })},
+	{"Skor", func(cf *CloudflareIPData) string { return strconv.FormatFloat(cf.Score, 'f', 2, 64) }, readFloat(func(cf *CloudflareIPData) *float64 { return &cf.Score })},
+	{"Latensi Tanpa Beban", func(cf *CloudflareIPData) string { return formatMillis(cf.IdleDelay) }, readMillis(func(cf *CloudflareIPData) *time.Duration { return &cf.IdleDelay })},
+}
+
+// Hasil sebelumnya, dibuat jika belum ada (saat membaca kolom pengujian ulang)
+func (cf *CloudflareIPData) previous() *CloudflareIPData {
+	if cf.Previous == nil {
+		cf.Previous = &CloudflareIPData{PingData: &PingData{IP: cf.IP, Port: cf.Port}}
+	}
+	return cf.Previous
+}
+
+// Jumlah paket pengujian latensi, kosong jika pengujian latensi tidak dijalankan
+func pingCount(cf *CloudflareIPData, n int) string {
+	if cf.Sended == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func csvHeader() []string {
+	header := make([]string, len(csvColumns))
+	for i, c := range csvColumns {
+		header[i] = c.name
+	}
+	return header
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds()*1000, 'f', 2, 32)
+}
+
+func formatMBps(speed float64) string {
+	return strconv.FormatFloat(speed/1024/1024, 'f', 2, 32)
+}
+
+func readString(field func(cf *CloudflareIPData) *string) func(cf *CloudflareIPData, v string) error {
+	return func(cf *CloudflareIPData, v string) error {
+		*field(cf) = v
+		return nil
+	}
+}
+
+func readInt(field func(cf *CloudflareIPData) *int) func(cf *CloudflareIPData, v string) error {
+	return func(cf *CloudflareIPData, v string) (err error) {
+		*field(cf), err = strconv.Atoi(v)
+		return
+	}
+}
+
+func readBool(field func(cf *CloudflareIPData) *bool) func(cf *CloudflareIPData, v string) error {
+	return func(cf *CloudflareIPData, v string) (err error) {
+		*field(cf), err = strconv.ParseBool(v)
+		return
+	}
+}
+
+func readMillis(field func(cf *CloudflareIPData) *time.Duration) func(cf *CloudflareIPData, v string) error {
+	return func(cf *CloudflareIPData, v string) error {
+		ms, err := strconv.ParseFloat(v, 64)
+		*field(cf) = time.Duration(ms * float64(time.Millisecond))
+		return err
+	}
+}
+
+func readMBps(field func(cf *CloudflareIPData) *float64) func(cf *CloudflareIPData, v string) error {
+	return func(cf *CloudflareIPData, v string) error {
+		speed, err := strconv.ParseFloat(v, 64)
+		*field(cf) = speed * 1024 * 1024
+		return err
+	}
+}
+
+func readFloat(field func(cf *CloudflareIPData) *float64) func(cf *CloudflareIPData, v string) error {
+	return func(cf *CloudflareIPData, v string) (err error) {
+		*field(cf), err = strconv.ParseFloat(v, 64)
+		return
+	}
+}
+
+func parseIPAddr(v string) (*net.IPAddr, error) {
+	ip := net.ParseIP(v)
+	if ip == nil {
+		return nil, fmt.Errorf("alamat IP [%s] tidak valid", v)
+	}
+	return &net.IPAddr{IP: ip}, nil
+}
+
+// ReadResults membaca kembali file hasil (CSV, JSON atau NDJSON, ditentukan dari isinya),
+// metadata pengujian hanya tersedia untuk file JSON/NDJSON
+func ReadResults(file string) (data []CloudflareIPData, run RunInfo, err error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, run, err
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")) // BOM UTF-8 (file yang disimpan ulang oleh perangkat lunak spreadsheet)
+	trimmed := bytes.TrimSpace(content)
+	firstLine := trimmed
+	if i := bytes.IndexByte(trimmed, '\n'); i >= 0 {
+		firstLine = trimmed[:i]
+	}
+	switch {
+	case len(trimmed) == 0:
+		return nil, run, fmt.Errorf("file hasil [%s] kosong", file)
+	case trimmed[0] != '{':
+		data, err = readCsv(content)
+	case bytes.Contains(firstLine, []byte(`"type"`)): // Baris pertama NDJSON adalah objek lengkap dengan "type"
+		data, run, err = readNDJSON(content)
+	default:
+		data, run, err = readJSON(content)
+	}
+	if err != nil {
+		return nil, run, fmt.Errorf("gagal membaca file hasil [%s]: %v", file, err)
+	}
+	return data, run, nil
+}
+
+// Membaca file CSV berdasarkan nama header, kolom yang tidak ada dibiarkan kosong sehingga file dari versi lama tetap dapat dibaca
+func readCsv(content []byte) ([]CloudflareIPData, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	index := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		index[strings.TrimSpace(name)] = i
+	}
+	if _, ok := index[csvColumns[0].name]; !ok {
+		return nil, fmt.Errorf("kolom [%s] tidak ditemukan", csvColumns[0].name)
+	}
+	data := make([]CloudflareIPData, 0, len(records)-1)
+	for line, record := range records[1:] {
+		cf := CloudflareIPData{PingData: &PingData{}}
+		for _, c := range csvColumns {
+			i, ok := index[c.name]
+			if !ok || c.read == nil || i >= len(record) || record[i] == "" {
+				continue
+			}
+			if err := c.read(&cf, strings.TrimSpace(record[i])); err != nil {
+				return nil, fmt.Errorf("baris %d kolom [%s]: %v", line+2, c.name, err)
+			}
+		}
+		if cf.IP == nil {
+			return nil, fmt.Errorf("baris %d kolom [%s]: alamat IP kosong", line+2, csvColumns[0].name)
+		}
+		data = append(data, cf)
+	}
+	return data, nil
+}
+
+func readJSON(content []byte) ([]CloudflareIPData, RunInfo, error) {
+	var doc struct {
+		SchemaVersion int          `json:"schema_version"`
+		Run           RunInfo      `json:"run"`
+		Results       []jsonResult `json:"results"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, doc.Run, err
+	}
+	if err := checkSchemaVersion(doc.SchemaVersion); err != nil {
+		return nil, doc.Run, err
+	}
+	data := make([]CloudflareIPData, 0, len(doc.Results))
+	for _, r := range doc.Results {
+		cf, err := r.toData()
+		if err != nil {
+			return nil, doc.Run, err
+		}
+		data = append(data, cf)
+	}
+	return data, doc.Run, nil
+}
+
+func readNDJSON(content []byte) (data []CloudflareIPData, run RunInfo, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record struct {
+			Type          string `json:"type"`
+			SchemaVersion int    `json:"schema_version"`
+		}
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, run, fmt.Errorf("baris %d: %v", line, err)
+		}
+		if err = checkSchemaVersion(record.SchemaVersion); err != nil {
+			return nil, run, fmt.Errorf("baris %d: %v", line, err)
+		}
+		switch record.Type {
+		case "run":
+			err = json.Unmarshal(scanner.Bytes(), &run)
+		case "result":
+			var r jsonResult
+			if err = json.Unmarshal(scanner.Bytes(), &r); err == nil {
+				var cf CloudflareIPData
+				if cf, err = r.toData(); err == nil {
+					data = append(data, cf)
+				}
+			}
+		}
+		if err != nil {
+			return nil, run, fmt.Errorf("baris %d: %v", line, err)
+		}
+	}
+	return data, run, scanner.Err()
+}
+
+func checkSchemaVersion(version int) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("versi skema %d lebih baru dari versi yang didukung (%d), perbarui program", version, SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func roundTripData() []CloudflareIPData {
+	return []CloudflareIPData{
+		{
+			PingData:      &PingData{IP: &net.IPAddr{IP: net.ParseIP("104.16.1.1")}, Port: 443, TLS: true, Sended: 4, Received: 3, Delay: 123450 * time.Microsecond, Jitter: 5 * time.Millisecond, Colo: "SIN", City: "Singapore", Country: "SG", Region: "Asia Pacific"},
+			DownloadSpeed: 5 * 1024 * 1024,
+			Download:      SpeedStats{Average: 4 * 1024 * 1024, Sustained: 5 * 1024 * 1024, Peak: 6 * 1024 * 1024, Bytes: 50000000, Elapsed: 10 * time.Second},
+			UploadSpeed:   2 * 1024 * 1024,
+			DownloadURL:   "https://speed.cloudflare.com/__down?bytes=200000000",
+			LoadedDelay:   180 * time.Millisecond,
+			IdleDelay:     120 * time.Millisecond,
+			Score:         87.5,
+		},
+		{
+			PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("2606:4700::1")}, Port: 8443, Delay: 80 * time.Millisecond, Colo: "HKG"}, // Hasil pencari proxy: tanpa pengujian latensi
+			Aborted:  true,
+		},
+	}
+}
+
+func TestReadResultsRoundTrip(t *testing.T) {
+	oldOutputs, oldRun := Outputs, Run
+	defer func() { Outputs, Run = oldOutputs, oldRun }()
+	Run = RunInfo{Tool: "scanner", Mode: "tcp", Port: 443, Provider: "cloudflare"}
+
+	tests := []struct {
+		name string
+		file string
+		run  bool // Metadata pengujian ikut dibaca
+	}{
+		{"csv", "result.csv", false},
+		{"json", "result.json", true},
+		{"ndjson", "result.ndjson", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.file)
+			Outputs = []string{file}
+			want := roundTripData()
+			ExportResults(want)
+			got, run, err := ReadResults(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("len = %d, want %d", len(got), len(want))
+			}
+			if tt.run && (run.Tool != Run.Tool || run.Port != Run.Port) {
+				t.Errorf("run = %+v, want %+v", run, Run)
+			}
+			for i := range want {
+				g, w := &got[i], &want[i]
+				if g.IP.String() != w.IP.String() || g.Port != w.Port || g.TLS != w.TLS || g.Colo != w.Colo || g.Country != w.Country {
+					t.Errorf("[%d] ip/port/tls/colo = %s %d %v %s, want %s %d %v %s", i, g.IP, g.Port, g.TLS, g.Colo, w.IP, w.Port, w.TLS, w.Colo)
+				}
+				if g.Sended != w.Sended || g.Received != w.Received || g.getLossRate() != w.getLossRate() {
+					t.Errorf("[%d] sent/received/loss = %d %d %.2f, want %d %d %.2f", i, g.Sended, g.Received, g.getLossRate(), w.Sended, w.Received, w.getLossRate())
+				}
+				if g.Delay != w.Delay || g.Jitter != w.Jitter || g.LoadedDelay != w.LoadedDelay || g.IdleDelay != w.IdleDelay {
+					t.Errorf("[%d] delay/jitter/loaded/idle = %v %v %v %v, want %v %v %v %v", i, g.Delay, g.Jitter, g.LoadedDelay, g.IdleDelay, w.Delay, w.Jitter, w.LoadedDelay, w.IdleDelay)
+				}
+				if g.DownloadSpeed != w.DownloadSpeed || g.Download.Peak != w.Download.Peak || g.Download.Bytes != w.Download.Bytes || g.UploadSpeed != w.UploadSpeed {
+					t.Errorf("[%d] download/peak/bytes/upload = %.0f %.0f %d %.0f, want %.0f %.0f %d %.0f", i, g.DownloadSpeed, g.Download.Peak, g.Download.Bytes, g.UploadSpeed, w.DownloadSpeed, w.Download.Peak, w.Download.Bytes, w.UploadSpeed)
+				}
+				if g.DownloadURL != w.DownloadURL || g.Aborted != w.Aborted || g.Score != w.Score {
+					t.Errorf("[%d] url/aborted/score = %q %v %.2f, want %q %v %.2f", i, g.DownloadURL, g.Aborted, g.Score, w.DownloadURL, w.Aborted, w.Score)
+				}
+			}
+		})
+	}
+}
+
+func TestReadCsv(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		ips  []string
+		err  string
+	}{
+		{"baseline 6 columns", "Alamat IP,Terkirim,Diterima,Tingkat Kehilangan Paket,Rata-rata Latensi,Kecepatan Unduh (MB/s)\n" +
+			"1.1.1.1,4,4,0.00,150.25,12.50\n1.0.0.1,4,3,0.25,160.00,0.00\n", []string{"1.1.1.1", "1.0.0.1"}, ""},
+		{"BOM and reordered columns", "\xef\xbb\xbfPort,Alamat IP\n8443,1.1.1.1\n", []string{"1.1.1.1"}, ""},
+		{"empty finder ping cells", "Alamat IP,Terkirim,Diterima,Tingkat Kehilangan Paket\n1.1.1.1,,,\n", []string{"1.1.1.1"}, ""},
+		{"empty IP cell", "Alamat IP,Terkirim\n1.1.1.1,4\n,4\n", nil, "baris 3 kolom [Alamat IP]: alamat IP kosong"},
+		{"invalid IP", "Alamat IP\n1.1.1\n", nil, "alamat IP [1.1.1] tidak valid"},
+		{"missing IP column", "Terkirim,Diterima\n4,4\n", nil, "kolom [Alamat IP] tidak ditemukan"},
+		{"invalid number", "Alamat IP,Terkirim\n1.1.1.1,abc\n", nil, "baris 2 kolom [Terkirim]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _, err := ReadResults(writeTemp(t, tt.csv))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ReadResults error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != len(tt.ips) {
+				t.Fatalf("len = %d, want %d", len(data), len(tt.ips))
+			}
+			for i, ip := range tt.ips {
+				if data[i].IP.String() != ip {
+					t.Errorf("[%d] ip = %s, want %s", i, data[i].IP, ip)
+				}
+			}
+		})
+	}
+
+	data, _, _ := ReadResults(writeTemp(t, "Alamat IP,Terkirim,Diterima,Tingkat Kehilangan Paket,Rata-rata Latensi,Kecepatan Unduh (MB/s)\n1.0.0.1,4,3,0.25,160.00,12.50\n"))
+	if v := data[0]; v.Sended != 4 || v.Received != 3 || v.getLossRate() != 0.25 || v.Delay != 160*time.Millisecond || v.DownloadSpeed != 12.5*1024*1024 {
+		t.Errorf("baseline row = %d %d %.2f %v %.0f, want 4 3 0.25 160ms %.0f", v.Sended, v.Received, v.getLossRate(), v.Delay, v.DownloadSpeed, 12.5*1024*1024)
+	}
+}
+
+func writeTemp(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "result.csv")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
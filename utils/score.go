@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// SortKey adalah dasar pengurutan: latency, speed, loss, jitter atau score (-sort), kosong berarti urutan default
+	// (kehilangan paket lalu latensi saat memilih IP untuk pengujian unduh, kecepatan untuk hasil akhir)
+	SortKey = ""
+	// ScoreWeights adalah bobot setiap metrik dalam skor gabungan (-score-weights)
+	ScoreWeights = map[string]float64{"latency": 1, "speed": 1, "loss": 1, "jitter": 0.5}
+)
+
+// Metrik skor gabungan, lower berarti nilai yang lebih kecil lebih baik
+var scoreMetrics = []struct {
+	name  string
+	lower bool
+	value func(cf *CloudflareIPData) float64
+}{
+	{"latency", true, func(cf *CloudflareIPData) float64 { return float64(cf.Delay) }},
+	{"speed", false, func(cf *CloudflareIPData) float64 { return cf.speed() }},
+	{"loss", true, func(cf *CloudflareIPData) float64 { return float64(cf.getLossRate()) }},
+	{"jitter", true, func(cf *CloudflareIPData) float64 { return float64(cf.Jitter) }},
+}
+
+// Memvalidasi [-sort] dan [-score-weights], dipanggil saat program dimulai.
+// Format bobot: latency=1,speed=2,loss=1,jitter=0.5, metrik yang tidak disebutkan tetap menggunakan bobot default
+func ParseSort(weights string) error {
+	SortKey = strings.ToLower(strings.TrimSpace(SortKey))
+	switch SortKey {
+	case "", "latency", "speed", "loss", "jitter", "score":
+	default:
+		return fmt.Errorf("dasar pengurutan [-sort %s] tidak valid, pilihan: latency, speed, loss, jitter, score", SortKey)
+	}
+	if strings.TrimSpace(weights) == "" {
+		return nil
+	}
+	for _, item := range strings.Split(weights, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		if _, ok := ScoreWeights[name]; !ok || len(kv) != 2 {
+			return fmt.Errorf("bobot skor [%s] tidak valid, format: latency=1,speed=1,loss=1,jitter=0.5", item)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil || w < 0 {
+			return fmt.Errorf("bobot skor [%s] tidak valid, bobot harus angka >= 0", item)
+		}
+		ScoreWeights[name] = w
+	}
+	total := 0.0
+	for _, w := range ScoreWeights {
+		total += w
+	}
+	if total == 0 {
+		return fmt.Errorf("bobot skor [-score-weights %s] tidak valid, setidaknya satu bobot harus lebih dari 0", weights)
+	}
+	return nil
+}
+
+// Kecepatan yang digunakan untuk pengurutan dan skor sesuai [-speed-sort]
+func (cf *CloudflareIPData) speed() float64 {
+	switch SpeedSortKey {
+	case "upload":
+		return cf.UploadSpeed
+	case "sum":
+		return cf.DownloadSpeed + cf.UploadSpeed
+	}
+	return cf.DownloadSpeed
+}
+
+// Menghitung skor gabungan (0~100, semakin tinggi semakin baik) setiap IP relatif terhadap IP lain dalam kumpulan yang sama:
+// setiap metrik dinormalisasi antara nilai terburuk (0) dan terbaik (1) lalu dirata-rata sesuai bobotnya,
+// metrik yang nilainya sama untuk semua IP (misalnya kecepatan sebelum pengujian unduh) tidak memengaruhi skor
+func computeScores(s []CloudflareIPData) {
+	var sums, weights = make([]float64, len(s)), 0.0
+	for _, m := range scoreMetrics {
+		w := ScoreWeights[m.name]
+		if w <= 0 || len(s) == 0 {
+			continue
+		}
+		lo, hi := m.value(&s[0]), m.value(&s[0])
+		for i := range s {
+			v := m.value(&s[i])
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi == lo {
+			continue
+		}
+		weights += w
+		for i := range s {
+			norm := (m.value(&s[i]) - lo) / (hi - lo)
+			if m.lower {
+				norm = 1 - norm
+			}
+			sums[i] += w * norm
+		}
+	}
+	for i := range s {
+		s[i].Score = 100
+		if weights > 0 {
+			s[i].Score = 100 * sums[i] / weights
+		}
+	}
+}
+
+// Membandingkan dua IP berdasarkan [-sort], ok false jika dasar pengurutan tidak membedakan keduanya
+// atau tidak berlaku (speed sebelum pengujian unduh, urutan default)
+func sortLess(a, b *CloudflareIPData) (less, ok bool) {
+	switch SortKey {
+	case "latency":
+		if a.Delay != b.Delay {
+			return a.Delay < b.Delay, true
+		}
+		return a.getLossRate() < b.getLossRate(), a.getLossRate() != b.getLossRate()
+	case "loss":
+		if a.getLossRate() != b.getLossRate() {
+			return a.getLossRate() < b.getLossRate(), true
+		}
+		return a.Delay < b.Delay, a.Delay != b.Delay
+	case "jitter":
+		if a.Jitter != b.Jitter {
+			return a.Jitter < b.Jitter, true
+		}
+		return a.Delay < b.Delay, a.Delay != b.Delay
+	case "score":
+		if a.Score != b.Score {
+			return a.Score > b.Score, true
+		}
+		return a.Delay < b.Delay, a.Delay != b.Delay
+	}
+	return false, false
+}
+
+// Menghitung skor lalu mengurutkan hasil pengujian latensi sesuai [-sort], urutan ini juga menentukan IP mana yang diuji kecepatan unduhnya
+func (s PingDelaySet) Sort() {
+	computeScores(s)
+	sort.Stable(s)
+}
+
+// Menghitung skor lalu mengurutkan hasil akhir sesuai [-sort]
+func (s DownloadSpeedSet) Sort() {
+	computeScores(s)
+	sort.Stable(s)
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Satuan ukuran data, KB/MB/GB desimal (1000) dan KiB/MiB/GiB biner (1024)
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000},
+	{"K", 1000}, {"M", 1000 * 1000}, {"G", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// Mem-parse ukuran data, contoh: 100MB, 1.5GB, 512KiB, 1000000
+func ParseByteSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v, unit = strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("ukuran data [%s] tidak valid, contoh: 100MB, 1.5GB, 512KiB", s)
+	}
+	if size := n * float64(unit); size >= math.MaxInt64 { // float64(math.MaxInt64) dibulatkan menjadi 2^63
+		return 0, fmt.Errorf("ukuran data [%s] terlalu besar", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// Format ukuran data agar mudah dibaca, contoh: 12.34 MB
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1000*1000*1000:
+		return fmt.Sprintf("%.2f GB", float64(n)/1000/1000/1000)
+	case n >= 1000*1000:
+		return fmt.Sprintf("%.2f MB", float64(n)/1000/1000)
+	case n >= 1000:
+		return fmt.Sprintf("%.2f KB", float64(n)/1000)
+	}
+	return fmt.Sprintf("%d B", n)
+}
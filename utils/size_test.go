@@ -0,0 +1,35 @@
+package utils
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"1000000", 1000000, false},
+		{"100MB", 100 * 1000 * 1000, false},
+		{"1.5GB", 1500 * 1000 * 1000, false},
+		{"512KiB", 512 * 1024, false},
+		{" 2 m ", 2 * 1000 * 1000, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"-1MB", 0, true},
+		{"NaN", 0, true},
+		{"nan", 0, true},
+		{"Inf", 0, true},
+		{"-Inf", 0, true},
+		{"+InfGB", 0, true},
+		{"1e30GB", 0, true},
+		{"9223372036854775807", 0, true},
+		{"9.3e18", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}